@@ -0,0 +1,195 @@
+// Package aes encrypts and decrypts media segments for METHOD=AES-128,
+// that is AES-128 in CBC mode with PKCS7 padding as defined in
+// section 5.2 of RFC 8216.
+package aes
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ShevaXu/hls"
+)
+
+// KeySize is the size of AES-128 keys and IVs in bytes.
+const KeySize = 16
+
+// chunk is the size of the blocks read from the source, a multiple of aes.BlockSize.
+const chunk = 32 * 1024
+
+// Errors returned by Decrypt.
+var (
+	ErrCiphertext = errors.New("aes: ciphertext is not a multiple of the block size")
+	ErrPadding    = errors.New("aes: invalid PKCS7 padding")
+)
+
+// IV returns the initialization vector of a segment: the IV attribute of
+// the key if present, otherwise the media sequence number of the segment
+// as a big-endian binary number in a 16-octet buffer.
+func IV(key *hls.Key, seqID int) ([]byte, error) {
+	if key != nil && key.IV != "" {
+		return ParseIV(key.IV)
+	}
+	iv := make([]byte, KeySize)
+	binary.BigEndian.PutUint64(iv[8:], uint64(seqID))
+	return iv, nil
+}
+
+// ParseIV parses the hexadecimal-sequence (0x-prefixed) of the IV attribute.
+func ParseIV(s string) ([]byte, error) {
+	if !strings.HasPrefix(s, "0x") && !strings.HasPrefix(s, "0X") {
+		return nil, fmt.Errorf("aes: IV %q is not a hexadecimal-sequence", s)
+	}
+	iv, err := hex.DecodeString(s[2:])
+	if err != nil {
+		return nil, fmt.Errorf("aes: IV %q: %s", s, err)
+	}
+	if len(iv) != KeySize {
+		return nil, fmt.Errorf("aes: IV %q is not 128 bits", s)
+	}
+	return iv, nil
+}
+
+// Encrypt reads the segment from src and writes it encrypted to dst.
+func Encrypt(dst io.Writer, src io.Reader, key, iv []byte) error {
+	block, err := newBlock(key, iv)
+	if err != nil {
+		return err
+	}
+	mode := cipher.NewCBCEncrypter(block, iv)
+	buf := make([]byte, chunk+aes.BlockSize)
+	for {
+		n, err := io.ReadFull(src, buf[:chunk])
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			padded := pad(buf[:n])
+			mode.CryptBlocks(padded, padded)
+			_, err = dst.Write(padded)
+			return err
+		}
+		if err != nil {
+			return err
+		}
+		mode.CryptBlocks(buf[:n], buf[:n])
+		if _, err = dst.Write(buf[:n]); err != nil {
+			return err
+		}
+	}
+}
+
+// Decrypt reads the encrypted segment from src and writes it decrypted to dst.
+func Decrypt(dst io.Writer, src io.Reader, key, iv []byte) error {
+	block, err := newBlock(key, iv)
+	if err != nil {
+		return err
+	}
+	mode := cipher.NewCBCDecrypter(block, iv)
+	buf := make([]byte, chunk)
+	var last []byte // the last block is held back to strip the padding
+	for {
+		n, rerr := io.ReadFull(src, buf)
+		if rerr != nil && rerr != io.EOF && rerr != io.ErrUnexpectedEOF {
+			return rerr
+		}
+		if n%aes.BlockSize != 0 {
+			return ErrCiphertext
+		}
+		if n > 0 {
+			mode.CryptBlocks(buf[:n], buf[:n])
+			if _, err = dst.Write(last); err != nil {
+				return err
+			}
+			if _, err = dst.Write(buf[:n-aes.BlockSize]); err != nil {
+				return err
+			}
+			last = append(last[:0], buf[n-aes.BlockSize:n]...)
+		}
+		if rerr != nil {
+			break
+		}
+	}
+	if last == nil {
+		return ErrCiphertext
+	}
+	last, err = unpad(last)
+	if err != nil {
+		return err
+	}
+	_, err = dst.Write(last)
+	return err
+}
+
+// EncryptSegment encrypts the segment data with the key material for the
+// EXT-X-KEY which applies to the segment with the media sequence number seqID.
+func EncryptSegment(data, material []byte, key *hls.Key, seqID int) ([]byte, error) {
+	iv, err := segmentIV(key, seqID)
+	if err != nil {
+		return nil, err
+	}
+	out := new(bytes.Buffer)
+	if err = Encrypt(out, bytes.NewReader(data), material, iv); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// DecryptSegment decrypts the segment data with the key material for the
+// EXT-X-KEY which applies to the segment with the media sequence number seqID.
+func DecryptSegment(data, material []byte, key *hls.Key, seqID int) ([]byte, error) {
+	iv, err := segmentIV(key, seqID)
+	if err != nil {
+		return nil, err
+	}
+	out := new(bytes.Buffer)
+	if err = Decrypt(out, bytes.NewReader(data), material, iv); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+func segmentIV(key *hls.Key, seqID int) ([]byte, error) {
+	if key == nil || key.Method != hls.KeyMethodAES128 {
+		return nil, errors.New("aes: key method is not AES-128")
+	}
+	return IV(key, seqID)
+}
+
+func newBlock(key, iv []byte) (cipher.Block, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("aes: key is %d bytes, not %d", len(key), KeySize)
+	}
+	if len(iv) != KeySize {
+		return nil, fmt.Errorf("aes: IV is %d bytes, not %d", len(iv), KeySize)
+	}
+	return aes.NewCipher(key)
+}
+
+// pad appends the PKCS7 padding, b must have room for a block.
+func pad(b []byte) []byte {
+	n := aes.BlockSize - len(b)%aes.BlockSize
+	for i := 0; i < n; i++ {
+		b = append(b, byte(n))
+	}
+	return b
+}
+
+func unpad(b []byte) ([]byte, error) {
+	if len(b) == 0 {
+		return nil, ErrPadding
+	}
+	n := int(b[len(b)-1])
+	if n == 0 || n > aes.BlockSize || n > len(b) {
+		return nil, ErrPadding
+	}
+	for _, v := range b[len(b)-n:] {
+		if int(v) != n {
+			return nil, ErrPadding
+		}
+	}
+	return b[:len(b)-n], nil
+}
@@ -0,0 +1,101 @@
+package aes_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/ShevaXu/hls"
+	"github.com/ShevaXu/hls/aes"
+)
+
+func mustHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// CBC-AES128 sample of NIST SP 800-38A F.2.1, followed by a padding block.
+func TestEncryptNIST(t *testing.T) {
+	key := mustHex("2b7e151628aed2a6abf7158809cf4f3c")
+	iv := mustHex("000102030405060708090a0b0c0d0e0f")
+	plain := mustHex("6bc1bee22e409f96e93d7e117393172aae2d8a571e03ac9c9eb76fac45af8e51")
+	out := new(bytes.Buffer)
+	if err := aes.Encrypt(out, bytes.NewReader(plain), key, iv); err != nil {
+		t.Fatal(err)
+	}
+	if out.Len() != 48 {
+		t.Fatalf("Expected 48 bytes with padding, got %d", out.Len())
+	}
+	if exp := mustHex("7649abac8119b246cee98e9b12e9197d5086cb9b507219ee95db113a917678b2"); !bytes.Equal(out.Bytes()[:32], exp) {
+		t.Errorf("Expected %x, got %x", exp, out.Bytes()[:32])
+	}
+}
+
+func TestSegmentRoundTrip(t *testing.T) {
+	material := mustHex("000102030405060708090a0b0c0d0e0f")
+	key := &hls.Key{Method: "AES-128", URI: "key.bin"}
+	for _, size := range []int{0, 1, 15, 16, 17, 32*1024 - 1, 32 * 1024, 100000} {
+		data := make([]byte, size)
+		for i := range data {
+			data[i] = byte(i)
+		}
+		enc, err := aes.EncryptSegment(data, material, key, 7)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(enc)%16 != 0 || len(enc) <= size {
+			t.Errorf("Size %d: unexpected encrypted size %d", size, len(enc))
+		}
+		dec, err := aes.DecryptSegment(enc, material, key, 7)
+		if err != nil {
+			t.Fatalf("Size %d: %s", size, err)
+		}
+		if !bytes.Equal(dec, data) {
+			t.Errorf("Size %d: round trip mismatch", size)
+		}
+		if dec, err = aes.DecryptSegment(enc, material, key, 8); err == nil && bytes.Equal(dec, data) {
+			t.Errorf("Size %d: expected a different IV for another sequence number", size)
+		}
+	}
+	if _, err := aes.EncryptSegment(nil, material, &hls.Key{Method: "SAMPLE-AES"}, 0); err == nil {
+		t.Error("Expected error for SAMPLE-AES")
+	}
+}
+
+func TestIV(t *testing.T) {
+	iv, err := aes.IV(&hls.Key{Method: "AES-128"}, 258)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := mustHex("00000000000000000000000000000102"); !bytes.Equal(iv, exp) {
+		t.Errorf("Expected %x, got %x", exp, iv)
+	}
+	iv, err = aes.IV(&hls.Key{Method: "AES-128", IV: "0x0102030405060708090A0B0C0D0E0F10"}, 258)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := mustHex("0102030405060708090a0b0c0d0e0f10"); !bytes.Equal(iv, exp) {
+		t.Errorf("Expected %x, got %x", exp, iv)
+	}
+	for _, s := range []string{"0102030405060708090A0B0C0D0E0F10", "0x0102", "0xZZ"} {
+		if _, err = aes.IV(&hls.Key{Method: "AES-128", IV: s}, 0); err == nil {
+			t.Errorf("Expected error for IV %q", s)
+		}
+	}
+}
+
+func TestDecryptErrors(t *testing.T) {
+	key := make([]byte, 16)
+	if err := aes.Decrypt(new(bytes.Buffer), bytes.NewReader(make([]byte, 15)), key, key); err != aes.ErrCiphertext {
+		t.Errorf("Expected %v, got %v", aes.ErrCiphertext, err)
+	}
+	if err := aes.Decrypt(new(bytes.Buffer), bytes.NewReader(nil), key, key); err != aes.ErrCiphertext {
+		t.Errorf("Expected %v, got %v", aes.ErrCiphertext, err)
+	}
+	if err := aes.Decrypt(new(bytes.Buffer), bytes.NewReader(make([]byte, 16)), make([]byte, 8), key); err == nil {
+		t.Error("Expected error for short key")
+	}
+}
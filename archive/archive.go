@@ -0,0 +1,282 @@
+// Package archive reads, verifies and writes HLS packages stored as files:
+// a master playlist, its media playlists and the segments, keys and
+// initialization sections they refer to.
+package archive
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ShevaXu/hls"
+	"github.com/ShevaXu/hls/loader"
+)
+
+// Package is an HLS package read from a file system.
+type Package struct {
+	FS     fs.FS
+	Path   string // path of the master playlist in FS
+	Master *hls.MasterPlaylist
+	Media  map[string]*hls.MediaPlaylist // media playlists by path in FS
+}
+
+// File is a file referred to by a media playlist.
+type File struct {
+	Path     string // path in the file system
+	Kind     hls.URIKind
+	Limit    int64  // length of the byte range, zero for the whole file
+	Offset   int64  // offset of the byte range
+	Playlist string // path of the media playlist referring to the file
+}
+
+// Read reads the master playlist at name and all its local media playlists.
+// It returns an error if any of them can not be read.
+func Read(fsys fs.FS, name string) (*Package, error) {
+	res, err := loader.NewFS(fsys).Load(context.Background(), name)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range res.Errors {
+		if !isRemote(e.URI) {
+			return nil, e
+		}
+	}
+	pkg := &Package{FS: fsys, Path: name, Master: res.Master, Media: make(map[string]*hls.MediaPlaylist)}
+	for _, v := range res.Master.Variants {
+		if v.Chunklist != nil {
+			pkg.Media[res.URIs[v.URI]] = v.Chunklist
+		}
+		for _, alt := range v.Alternatives {
+			if m := res.Renditions[alt]; m != nil {
+				pkg.Media[res.URIs[alt.URI]] = m
+			}
+		}
+	}
+	return pkg, nil
+}
+
+// Files returns the local files referred to by the media playlists, each
+// byte range once. URIs on other hosts are skipped. Byte ranges without
+// offset have the offset resolved by the decoder. It fails if a URI can not
+// be resolved in the file system.
+func (pkg *Package) Files() ([]File, error) {
+	var files []File
+	seen := make(map[File]bool)
+	add := func(f File) {
+		key := f
+		key.Playlist = ""
+		if !seen[key] {
+			seen[key] = true
+			files = append(files, f)
+		}
+	}
+	for _, name := range sortedKeys(pkg.Media) {
+		p := pkg.Media[name]
+		resolve := func(uri string) (string, bool, error) {
+			if isRemote(uri) {
+				return "", false, nil
+			}
+			file, err := (&loader.FSOpener{FS: pkg.FS}).Resolve(name, uri)
+			if err != nil {
+				return "", false, &loader.URIError{URI: uri, Err: err}
+			}
+			return file, true, nil
+		}
+		addKeys := func(keys ...*hls.Key) error {
+			for _, key := range keys {
+				if key == nil || key.Method == hls.KeyMethodNone {
+					continue
+				}
+				file, ok, err := resolve(key.URI)
+				if err != nil {
+					return err
+				}
+				if ok {
+					add(File{Path: file, Kind: hls.URIKey, Playlist: name})
+				}
+			}
+			return nil
+		}
+		addMap := func(m *hls.Map) error {
+			if m == nil {
+				return nil
+			}
+			file, ok, err := resolve(m.URI)
+			if err != nil {
+				return err
+			}
+			if ok {
+				add(File{Path: file, Kind: hls.URIMap, Limit: int64(m.Limit), Offset: int64(m.Offset), Playlist: name})
+			}
+			return nil
+		}
+		if err := addKeys(append([]*hls.Key{p.Key}, p.Keys...)...); err != nil {
+			return nil, err
+		}
+		if err := addMap(p.Map); err != nil {
+			return nil, err
+		}
+		for _, seg := range p.Segments[:p.Count()] {
+			if seg == nil {
+				continue
+			}
+			if err := addKeys(append([]*hls.Key{seg.Key}, seg.Keys...)...); err != nil {
+				return nil, err
+			}
+			if err := addMap(seg.Map); err != nil {
+				return nil, err
+			}
+			file, ok, err := resolve(seg.URI)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				continue
+			}
+			add(File{Path: file, Kind: hls.URISegment, Limit: int64(seg.Limit), Offset: int64(seg.Offset), Playlist: name})
+		}
+	}
+	return files, nil
+}
+
+// Verify checks that every file referred to by the media playlists exists
+// and that byte ranges are within the file size. It returns all failures.
+func (pkg *Package) Verify() []error {
+	files, err := pkg.Files()
+	if err != nil {
+		return []error{err}
+	}
+	var errs []error
+	sizes := make(map[string]int64)
+	for _, f := range files {
+		size, ok := sizes[f.Path]
+		if !ok {
+			info, err := fs.Stat(pkg.FS, f.Path)
+			if err != nil {
+				errs = append(errs, &loader.URIError{URI: f.Path, Err: err})
+				sizes[f.Path] = -1
+				continue
+			}
+			size = info.Size()
+			sizes[f.Path] = size
+		}
+		if size >= 0 && f.Limit > 0 && f.Offset+f.Limit > size {
+			errs = append(errs, &loader.URIError{URI: f.Path, Err: fmt.Errorf("byte range %d@%d beyond size %d", f.Limit, f.Offset, size)})
+		}
+	}
+	return errs
+}
+
+// Write writes the package to the directory: the master playlist at the
+// root under its base name, and every media playlist and local file at
+// its path relative to the master playlist. Files outside the directory
+// of the master playlist go to the ext subdirectory. The URIs of the
+// written playlists are rewritten relative to them, remote URIs are kept.
+func (pkg *Package) Write(dir string) error {
+	files, err := pkg.Files()
+	if err != nil {
+		return err
+	}
+	root := path.Dir(pkg.Path)
+	dest := func(name string) string {
+		if root == "." {
+			return name
+		}
+		if strings.HasPrefix(name, root+"/") {
+			return name[len(root)+1:]
+		}
+		return path.Join("ext", name)
+	}
+	rewriter := func(playlist string) hls.URIRewriter {
+		from := path.Dir(dest(playlist))
+		return hls.URIRewriterFunc(func(_ hls.URIKind, uri string) string {
+			if isRemote(uri) {
+				return uri
+			}
+			name, err := (&loader.FSOpener{FS: pkg.FS}).Resolve(playlist, uri)
+			if err != nil {
+				return uri
+			}
+			return relPath(from, dest(name))
+		})
+	}
+	if err = writeFile(dir, path.Base(pkg.Path), pkg.Master.EncodeWith(rewriter(pkg.Path)).Bytes()); err != nil {
+		return err
+	}
+	for _, name := range sortedKeys(pkg.Media) {
+		if err = writeFile(dir, dest(name), pkg.Media[name].EncodeWith(rewriter(name)).Bytes()); err != nil {
+			return err
+		}
+	}
+	copied := make(map[string]bool)
+	for _, f := range files {
+		if copied[f.Path] {
+			continue
+		}
+		copied[f.Path] = true
+		if err = copyFile(pkg.FS, f.Path, filepath.Join(dir, filepath.FromSlash(dest(f.Path)))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeFile(dir, name string, data []byte) error {
+	file := filepath.Join(dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(file, data, 0644)
+}
+
+func copyFile(fsys fs.FS, name, file string) error {
+	src, err := fsys.Open(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	if err = os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	dst, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+	return dst.Close()
+}
+
+// relPath returns the slash separated path of to relative to the directory from.
+func relPath(from, to string) string {
+	rel, err := filepath.Rel(filepath.FromSlash(from), filepath.FromSlash(to))
+	if err != nil {
+		return to
+	}
+	return filepath.ToSlash(rel)
+}
+
+// isRemote reports whether the URI has a scheme or a host, like remote
+// URLs and skd: or data: key URIs.
+func isRemote(uri string) bool {
+	u, err := url.Parse(uri)
+	return err == nil && (u.IsAbs() || u.Host != "")
+}
+
+func sortedKeys(m map[string]*hls.MediaPlaylist) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
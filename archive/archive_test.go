@@ -0,0 +1,113 @@
+package archive_test
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/ShevaXu/hls/archive"
+)
+
+func testPackage() fstest.MapFS {
+	return fstest.MapFS{
+		"vod/master.m3u8": {Data: []byte(`#EXTM3U
+#EXT-X-STREAM-INF:BANDWIDTH=1000000
+hd/index.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=500000
+https://cdn.example.com/sd/index.m3u8
+`)},
+		"vod/hd/index.m3u8": {Data: []byte(`#EXTM3U
+#EXT-X-VERSION:5
+#EXT-X-TARGETDURATION:4
+#EXT-X-MAP:URI="init.mp4"
+#EXT-X-KEY:METHOD=AES-128,URI="/keys/k.key"
+#EXT-X-BYTERANGE:100@0
+#EXTINF:4.000,
+media.mp4
+#EXT-X-BYTERANGE:50
+#EXTINF:4.000,
+media.mp4
+#EXTINF:4.000,
+https://cdn.example.com/hd/ad.mp4
+#EXT-X-ENDLIST
+`)},
+		"vod/hd/init.mp4":  {Data: make([]byte, 10)},
+		"vod/hd/media.mp4": {Data: make([]byte, 150)},
+		"keys/k.key":       {Data: make([]byte, 16)},
+	}
+}
+
+func TestReadAndVerify(t *testing.T) {
+	fsys := testPackage()
+	pkg, err := archive.Read(fsys, "vod/master.m3u8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pkg.Media) != 1 || pkg.Media["vod/hd/index.m3u8"] == nil {
+		t.Fatalf("Expected the local media playlist only, got %v", pkg.Media)
+	}
+	files, err := pkg.Files()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 4 {
+		t.Fatalf("Expected key, map and 2 ranges, got %+v", files)
+	}
+	if f := files[3]; f.Path != "vod/hd/media.mp4" || f.Offset != 100 || f.Limit != 50 {
+		t.Errorf("Unexpected implicit range %+v", f)
+	}
+	if errs := pkg.Verify(); len(errs) != 0 {
+		t.Errorf("Unexpected errors %v", errs)
+	}
+
+	fsys["vod/hd/media.mp4"].Data = make([]byte, 120)
+	delete(fsys, "keys/k.key")
+	errs := pkg.Verify()
+	if len(errs) != 2 || !strings.Contains(errs[0].Error(), "keys/k.key") || !strings.Contains(errs[1].Error(), "beyond size 120") {
+		t.Errorf("Unexpected errors %v", errs)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	pkg, err := archive.Read(testPackage(), "vod/master.m3u8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err = pkg.Write(dir); err != nil {
+		t.Fatal(err)
+	}
+	written, err := archive.Read(os.DirFS(dir), "master.m3u8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if errs := written.Verify(); len(errs) != 0 {
+		t.Errorf("Unexpected errors %v", errs)
+	}
+	media, err := os.ReadFile(dir + "/hd/index.m3u8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	master, err := os.ReadFile(dir + "/master.m3u8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(master), "\nhd/index.m3u8\n") || !strings.Contains(string(master), "\nhttps://cdn.example.com/sd/index.m3u8\n") {
+		t.Errorf("Unexpected master playlist\n%s", master)
+	}
+	for _, exp := range []string{`URI="../ext/keys/k.key"`, `URI="init.mp4"`, "\nmedia.mp4\n", "\nhttps://cdn.example.com/hd/ad.mp4\n",
+		"#EXT-X-BYTERANGE:100@0\n", "#EXT-X-BYTERANGE:50@100\n"} {
+		if !strings.Contains(string(media), exp) {
+			t.Errorf("Expected %q in\n%s", exp, media)
+		}
+	}
+	// the ranges of the written playlist point at the same bytes
+	files, err := written.Files()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f := files[len(files)-1]; f.Path != "hd/media.mp4" || f.Offset != 100 || f.Limit != 50 {
+		t.Errorf("Unexpected implicit range %+v", f)
+	}
+}
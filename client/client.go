@@ -0,0 +1,166 @@
+// Package client follows live media playlists over HTTP: it reloads the
+// playlist as section 6.3.4 of RFC 8216 prescribes and delivers the new
+// segments as they appear.
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/ShevaXu/hls"
+)
+
+// Defaults of the reload settings of Client.
+const (
+	DefaultMinReload = time.Second
+	DefaultRetries   = 3
+)
+
+// ErrNotMedia is returned when the URL does not refer to a media playlist.
+var ErrNotMedia = errors.New("client: not a media playlist")
+
+// Client follows a media playlist.
+type Client struct {
+	URL        *url.URL
+	HTTPClient *http.Client  // http.DefaultClient if nil
+	Strict     bool          // decode the playlist in strict mode
+	MinReload  time.Duration // shortest wait between reloads, DefaultMinReload if zero
+	Retries    int           // attempts of a failed reload before Follow fails, DefaultRetries if zero
+
+	// OnReload, if set, is called with every loaded playlist before its
+	// new segments are delivered.
+	OnReload func(p *hls.MediaPlaylist)
+}
+
+// New creates a client following the media playlist at rawurl.
+func New(rawurl string, httpClient *http.Client) (*Client, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{URL: u, HTTPClient: httpClient}, nil
+}
+
+// Load fetches and decodes the media playlist once. It returns the
+// playlist along with its URL after redirects, to resolve relative URIs.
+func (c *Client) Load(ctx context.Context) (*hls.MediaPlaylist, *url.URL, error) {
+	req, err := http.NewRequest(http.MethodGet, c.URL.String(), nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("client: GET %s: %s", c.URL, resp.Status)
+	}
+	p, listType, err := hls.DecodeFrom(resp.Body, c.Strict)
+	if err != nil {
+		return nil, nil, err
+	}
+	if listType != hls.ListTypeMedia {
+		return nil, nil, ErrNotMedia
+	}
+	return p.(*hls.MediaPlaylist), resp.Request.URL, nil
+}
+
+// Follow loads the playlist and sends every segment to out, then reloads
+// it and sends the segments which appeared since, until the playlist ends
+// with EXT-X-ENDLIST or the context is cancelled. Delivered segments have
+// their SeqID set and absolute segment, key and map URIs. It waits the
+// target duration between reloads, or half of it when the playlist did not
+// change, and MinReload at least. A failed reload is retried with an
+// exponential backoff starting at MinReload. If the media sequence number
+// of a reload goes back, e.g. as the origin restarted, the segments are
+// delivered again from the new window, the first one with a discontinuity.
+// Follow returns nil at the end
+// of the playlist, the context error on cancellation, or the error of the
+// first load or of the last attempt of a reload. It does not close out.
+func (c *Client) Follow(ctx context.Context, out chan<- *hls.MediaSegment) error {
+	minReload, retries := c.MinReload, c.Retries
+	if minReload <= 0 {
+		minReload = DefaultMinReload
+	}
+	if retries <= 0 {
+		retries = DefaultRetries
+	}
+	next := -1  // media sequence number of the next segment to deliver
+	seqNo := -1 // media sequence number of the previous reload
+	for {
+		p, base, err := c.Load(ctx)
+		for backoff, n := minReload, 0; err != nil && err != ErrNotMedia && next >= 0 && n < retries; backoff, n = 2*backoff, n+1 {
+			if err = sleep(ctx, backoff); err != nil {
+				return err
+			}
+			p, base, err = c.Load(ctx)
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+		p = p.ResolveURIs(base)
+		if c.OnReload != nil {
+			c.OnReload(p)
+		}
+		reset := p.SeqNo < seqNo
+		if reset {
+			next = p.SeqNo
+		}
+		seqNo = p.SeqNo
+		changed := false
+		for i, seg := range p.Segments[:p.Count()] {
+			seqID := p.SeqNo + i
+			if seg == nil || seqID < next {
+				continue
+			}
+			seg.SeqID = seqID
+			if reset {
+				seg.Discontinuity, reset = true, false
+			}
+			select {
+			case out <- seg:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			next = seqID + 1
+			changed = true
+		}
+		if p.Closed {
+			return nil
+		}
+		wait := time.Duration(p.TargetDuration * float64(time.Second))
+		if !changed {
+			wait /= 2
+		}
+		if wait < minReload {
+			wait = minReload
+		}
+		if err = sleep(ctx, wait); err != nil {
+			return err
+		}
+	}
+}
+
+// sleep waits for d, or returns the context error if it is done first.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		timer.Stop()
+		return ctx.Err()
+	}
+}
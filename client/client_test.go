@@ -0,0 +1,193 @@
+package client_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ShevaXu/hls"
+	"github.com/ShevaXu/hls/client"
+)
+
+// liveServer serves a live playlist gaining a segment every other reload.
+type liveServer struct {
+	mu      sync.Mutex
+	reloads int
+	end     int // reloads before EXT-X-ENDLIST
+}
+
+func (s *liveServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	n := s.reloads
+	s.reloads++
+	s.mu.Unlock()
+	last := 2 + n/2
+	first := last - 2
+	var b strings.Builder
+	fmt.Fprintf(&b, "#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-MEDIA-SEQUENCE:%d\n#EXT-X-TARGETDURATION:0.05\n", first)
+	if first == 0 {
+		b.WriteString("#EXT-X-KEY:METHOD=AES-128,URI=\"../keys/1.key\"\n")
+	}
+	for i := first; i <= last; i++ {
+		fmt.Fprintf(&b, "#EXTINF:0.050,\nseg%d.ts\n", i)
+	}
+	if n >= s.end {
+		b.WriteString("#EXT-X-ENDLIST\n")
+	}
+	w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+	w.Write([]byte(b.String()))
+}
+
+func TestFollow(t *testing.T) {
+	srv := httptest.NewServer(&liveServer{end: 6})
+	defer srv.Close()
+	c, err := client.New(srv.URL+"/live/index.m3u8", srv.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.MinReload = 10 * time.Millisecond
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	out := make(chan *hls.MediaSegment)
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Follow(ctx, out)
+		close(out)
+	}()
+	var segs []*hls.MediaSegment
+	for seg := range out {
+		segs = append(segs, seg)
+	}
+	if err = <-done; err != nil {
+		t.Fatal(err)
+	}
+	// the last reload lists segments 3 to 5
+	if len(segs) != 6 {
+		t.Fatalf("Expected 6 segments, got %d", len(segs))
+	}
+	for i, seg := range segs {
+		if seg.SeqID != i || seg.URI != fmt.Sprintf("%s/live/seg%d.ts", srv.URL, i) {
+			t.Errorf("Segment %d: unexpected %d %s", i, seg.SeqID, seg.URI)
+		}
+	}
+	if segs[0].Key == nil || segs[0].Key.URI != srv.URL+"/keys/1.key" {
+		t.Errorf("Expected absolute key URI, got %+v", segs[0].Key)
+	}
+}
+
+func TestFollowCancel(t *testing.T) {
+	srv := httptest.NewServer(&liveServer{end: 1 << 30})
+	defer srv.Close()
+	c, err := client.New(srv.URL+"/index.m3u8", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.MinReload = 10 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	out := make(chan *hls.MediaSegment, 100)
+	done := make(chan error, 1)
+	go func() { done <- c.Follow(ctx, out) }()
+	<-out
+	cancel()
+	select {
+	case err = <-done:
+		if err != context.Canceled {
+			t.Errorf("Expected %v, got %v", context.Canceled, err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Follow did not return after cancel")
+	}
+}
+
+func TestFollowMaster(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=100000\nlow.m3u8\n"))
+	}))
+	defer srv.Close()
+	c, _ := client.New(srv.URL, nil)
+	if err := c.Follow(context.Background(), make(chan *hls.MediaSegment)); err != client.ErrNotMedia {
+		t.Errorf("Expected %v, got %v", client.ErrNotMedia, err)
+	}
+}
+
+func TestFollowRetry(t *testing.T) {
+	var mu sync.Mutex
+	var reloads []time.Time
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		reloads = append(reloads, time.Now())
+		n := len(reloads)
+		mu.Unlock()
+		switch n {
+		case 2, 3: // the first two reloads fail
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		case 4:
+			w.Write([]byte("#EXTM3U\n#EXT-X-TARGETDURATION:0\n#EXTINF:0,\nseg0.ts\n#EXTINF:0,\nseg1.ts\n#EXT-X-ENDLIST\n"))
+			return
+		}
+		w.Write([]byte("#EXTM3U\n#EXT-X-TARGETDURATION:0\n#EXTINF:0,\nseg0.ts\n"))
+	}))
+	defer srv.Close()
+	c, _ := client.New(srv.URL, nil)
+	c.MinReload = 20 * time.Millisecond
+	out := make(chan *hls.MediaSegment, 10)
+	if err := c.Follow(context.Background(), out); err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 2 {
+		t.Errorf("Expected 2 segments, got %d", len(out))
+	}
+	// the reloads wait MinReload at least, then back off
+	for i, min := range []time.Duration{20, 20, 40} {
+		if d := reloads[i+1].Sub(reloads[i]); d < min*time.Millisecond {
+			t.Errorf("Reload %d after %v, expected %v at least", i+1, d, min*time.Millisecond)
+		}
+	}
+
+	reloads = nil
+	c.Retries = 1
+	if err := c.Follow(context.Background(), make(chan *hls.MediaSegment, 10)); err == nil || len(reloads) != 3 {
+		t.Errorf("Expected an error after 3 loads, got %v after %d", err, len(reloads))
+	}
+}
+
+func TestFollowSequenceReset(t *testing.T) {
+	var mu sync.Mutex
+	reloads := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		n := reloads
+		reloads++
+		mu.Unlock()
+		switch n {
+		case 0:
+			w.Write([]byte("#EXTM3U\n#EXT-X-MEDIA-SEQUENCE:5\n#EXT-X-TARGETDURATION:0\n#EXTINF:0,\nseg5.ts\n#EXTINF:0,\nseg6.ts\n"))
+		default: // the origin restarted
+			w.Write([]byte("#EXTM3U\n#EXT-X-MEDIA-SEQUENCE:0\n#EXT-X-TARGETDURATION:0\n#EXTINF:0,\nnew0.ts\n#EXTINF:0,\nnew1.ts\n#EXT-X-ENDLIST\n"))
+		}
+	}))
+	defer srv.Close()
+	c, _ := client.New(srv.URL+"/index.m3u8", nil)
+	c.MinReload = 10 * time.Millisecond
+	out := make(chan *hls.MediaSegment, 10)
+	if err := c.Follow(context.Background(), out); err != nil {
+		t.Fatal(err)
+	}
+	close(out)
+	var uris []string
+	for seg := range out {
+		uris = append(uris, strings.TrimPrefix(seg.URI, srv.URL+"/"))
+		if seg.Discontinuity != (seg.URI == srv.URL+"/new0.ts") {
+			t.Errorf("%s: unexpected discontinuity %v", seg.URI, seg.Discontinuity)
+		}
+	}
+	if strings.Join(uris, " ") != "seg5.ts seg6.ts new0.ts new1.ts" {
+		t.Errorf("Unexpected segments %v", uris)
+	}
+}
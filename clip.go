@@ -0,0 +1,79 @@
+package hls
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+// Clip returns a new closed VOD playlist of the segments covering the time
+// range [start, end) from the start of the first segment, end being
+// clamped to the end of the playlist. The first segment of the clip gets
+// the key and the map in effect for it and its program date time, the
+// media and discontinuity sequence numbers follow the source playlist.
+// If start is inside the first segment, EXT-X-START points at it, with
+// PRECISE=YES if precise is true.
+func (p *MediaPlaylist) Clip(start, end time.Duration, precise bool) (*MediaPlaylist, error) {
+	if start < 0 || end <= start {
+		return nil, errors.New("invalid clip range")
+	}
+	x := p.timeIndex()
+	if start >= x.end {
+		return nil, ErrNoSegment
+	}
+	from := sort.Search(len(x.offsets), func(i int) bool { return x.offsets[i] > start }) - 1
+	to := sort.Search(len(x.offsets), func(i int) bool { return x.offsets[i] >= end })
+
+	cp := p.slice(from, to)
+	cp.Closed = true
+	cp.MediaType = MediaTypeVOD
+	cp.ServerControl = nil
+	cp.Start = nil
+	if in := start - x.offsets[from]; in > 0 {
+		cp.Start = &StartPoint{TimeOffset: in.Seconds(), Precise: precise}
+	}
+	return cp, nil
+}
+
+// slice returns a copy of the playlist with the segments from to to, in
+// the order of the playlist. The first segment gets the key and the map in
+// effect for it and its program date time, the media and discontinuity
+// sequence numbers follow the source playlist: the discontinuities of the
+// segments left out are counted, the first segment keeps its own.
+func (p *MediaPlaylist) slice(from, to int) *MediaPlaylist {
+	x := p.timeIndex()
+	var key *MediaSegment // last segment with keys up to the first one
+	var init *Map
+	discontinuities := 0
+	for i, seg := range x.segs[:from+1] {
+		if seg.Key != nil {
+			key = seg
+		}
+		if seg.Map != nil {
+			init = seg.Map
+		}
+		if i < from && seg.Discontinuity {
+			discontinuities++
+		}
+	}
+
+	cp := p.clone()
+	segs := cp.segments()[from:to]
+	cp.Segments = segs
+	cp.capacity, cp.head, cp.tail, cp.count = len(segs), 0, 0, len(segs)
+	cp.SeqNo += from
+	cp.DiscontinuitySeq += discontinuities
+	cp.winsize = 0
+	first := segs[0]
+	if first.Key == nil && key != nil {
+		keys := make(map[*Key]*Key)
+		first.Key, first.Keys = copyKey(keys, key.Key), copyKeys(keys, key.Keys)
+	}
+	if first.Map == nil && init != nil {
+		first.Map = copyMap(init)
+	}
+	if first.ProgramDateTime.IsZero() {
+		first.ProgramDateTime = x.times[from]
+	}
+	return cp
+}
@@ -0,0 +1,134 @@
+package hls_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ShevaXu/hls"
+)
+
+const clipSource = `#EXTM3U
+#EXT-X-VERSION:6
+#EXT-X-MEDIA-SEQUENCE:100
+#EXT-X-TARGETDURATION:4
+#EXT-X-PLAYLIST-TYPE:VOD
+#EXT-X-KEY:METHOD=AES-128,URI="key1",IV=0x00000000000000000000000000000001
+#EXT-X-MAP:URI="init.mp4"
+#EXT-X-PROGRAM-DATE-TIME:2020-01-01T00:00:00Z
+#EXTINF:4.000,
+a.mp4
+#EXTINF:4.000,
+b.mp4
+#EXT-X-DISCONTINUITY
+#EXTINF:4.000,
+c.mp4
+#EXTINF:4.000,
+d.mp4
+#EXTINF:4.000,
+e.mp4
+#EXT-X-ENDLIST
+`
+
+func TestClip(t *testing.T) {
+	p, _ := hls.NewMediaPlaylist(0, 10)
+	if err := p.DecodeFrom(bytes.NewBufferString(clipSource), true); err != nil {
+		t.Fatal(err)
+	}
+	c, err := p.Clip(9*time.Second, 14*time.Second, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Count() != 2 || c.SeqNo != 102 || c.DiscontinuitySeq != 0 || !c.Closed || c.MediaType != hls.MediaTypeVOD {
+		t.Fatalf("Unexpected clip %d segments from %d/%d", c.Count(), c.SeqNo, c.DiscontinuitySeq)
+	}
+	first := c.Segments[0]
+	if first.URI != "c.mp4" || !first.Discontinuity {
+		t.Errorf("Unexpected first segment %+v", first)
+	}
+	if first.Key == nil || first.Key.URI != "key1" || first.Map == nil || first.Map.URI != "init.mp4" {
+		t.Errorf("Expected the effective key and map, got %+v %+v", first.Key, first.Map)
+	}
+	if !first.ProgramDateTime.Equal(time.Date(2020, 1, 1, 0, 0, 8, 0, time.UTC)) {
+		t.Errorf("Unexpected program date time %v", first.ProgramDateTime)
+	}
+	if c.Start == nil || c.Start.TimeOffset != 1 || !c.Start.Precise {
+		t.Errorf("Unexpected start %+v", c.Start)
+	}
+	out := c.String()
+	for _, tag := range []string{"#EXT-X-START:TIME-OFFSET=1,PRECISE=YES\n", "#EXT-X-MEDIA-SEQUENCE:102\n",
+		"#EXT-X-DISCONTINUITY\n#EXT-X-PROGRAM-DATE-TIME:2020-01-01T00:00:08Z\n#EXTINF:4.000,\nc.mp4\n", "#EXT-X-ENDLIST\n"} {
+		if !strings.Contains(out, tag) {
+			t.Errorf("Expected %q in\n%s", tag, out)
+		}
+	}
+	if strings.Contains(out, "#EXT-X-DISCONTINUITY-SEQUENCE") || strings.Contains(out, "a.mp4") {
+		t.Errorf("Unexpected clip\n%s", out)
+	}
+	// the source is unchanged
+	if p.Count() != 5 || p.Segments[2].Key != nil || !p.Segments[2].Discontinuity {
+		t.Error("Source playlist modified")
+	}
+	// decoding the clip gives the start point back
+	d, _ := hls.NewMediaPlaylist(0, 10)
+	if err := d.DecodeFrom(bytes.NewBufferString(out), true); err != nil {
+		t.Fatal(err)
+	}
+	if d.Start == nil || *d.Start != *c.Start {
+		t.Errorf("Expected start %+v, got %+v", c.Start, d.Start)
+	}
+}
+
+func TestClipBoundaries(t *testing.T) {
+	p, _ := hls.NewMediaPlaylist(0, 10)
+	if err := p.DecodeFrom(bytes.NewBufferString(clipSource), true); err != nil {
+		t.Fatal(err)
+	}
+	c, err := p.Clip(0, time.Hour, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Count() != 5 || c.Start != nil || c.SeqNo != 100 {
+		t.Errorf("Expected the whole playlist, got %d segments from %d start %+v", c.Count(), c.SeqNo, c.Start)
+	}
+	if c, _ := p.Clip(4*time.Second, 8*time.Second, false); c.Count() != 1 || c.Segments[0].URI != "b.mp4" {
+		t.Errorf("Expected b.mp4 only")
+	}
+	if c, _ := p.Clip(5*time.Second, 8*time.Second, false); c.Start == nil || c.Start.Precise ||
+		!strings.Contains(c.String(), "#EXT-X-START:TIME-OFFSET=1\n") {
+		t.Errorf("Expected an imprecise start, got %+v", c.Start)
+	}
+	if _, err := p.Clip(20*time.Second, 30*time.Second, false); err != hls.ErrNoSegment {
+		t.Errorf("Expected ErrNoSegment, got %v", err)
+	}
+	if _, err := p.Clip(8*time.Second, 8*time.Second, false); err == nil {
+		t.Error("Expected an error for an empty range")
+	}
+}
+
+func TestClipFirstDiscontinuity(t *testing.T) {
+	p, _ := hls.NewMediaPlaylist(0, 10)
+	src := strings.Replace(clipSource, "#EXTINF:4.000,\na.mp4", "#EXT-X-DISCONTINUITY\n#EXTINF:4.000,\na.mp4", 1)
+	if err := p.DecodeFrom(bytes.NewBufferString(src), true); err != nil {
+		t.Fatal(err)
+	}
+	// the discontinuity of the first segment is kept
+	c, _ := p.Clip(0, 4*time.Second, false)
+	if c.DiscontinuitySeq != 0 || !c.Segments[0].Discontinuity {
+		t.Errorf("Expected a.mp4 with its discontinuity, got %d %+v", c.DiscontinuitySeq, c.Segments[0])
+	}
+	// or counted once left out
+	c, _ = p.Clip(4*time.Second, 8*time.Second, false)
+	if c.DiscontinuitySeq != 1 || c.Segments[0].Discontinuity {
+		t.Errorf("Expected b.mp4 in timeline 1, got %d %+v", c.DiscontinuitySeq, c.Segments[0])
+	}
+	c, _ = p.Clip(12*time.Second, 16*time.Second, false)
+	if c.DiscontinuitySeq != 2 || c.Segments[0].URI != "d.mp4" {
+		t.Errorf("Expected d.mp4 in timeline 2, got %d %+v", c.DiscontinuitySeq, c.Segments[0])
+	}
+	// timelines are numbered alike
+	if st := p.Stats(); st.Timelines[1].DiscontinuitySeq != 2 || st.Timelines[1].FirstSeqID != 102 {
+		t.Errorf("Unexpected timelines %+v", st.Timelines)
+	}
+}
@@ -0,0 +1,126 @@
+package hls
+
+// clone returns a copy of the playlist which shares nothing with it apart
+// from Widevine tags and date ranges. Segments are copied in order into a
+// ring of the same capacity; the cache, the ad break in progress, the key
+// rotator and the subscribers are not copied.
+func (p *MediaPlaylist) clone() *MediaPlaylist {
+	segs := p.segments()
+	capacity := p.capacity
+	if capacity < len(segs) {
+		capacity = len(segs)
+	}
+	keys := make(map[*Key]*Key)
+	cp := &MediaPlaylist{
+		TargetDuration:   p.TargetDuration,
+		SeqNo:            p.SeqNo,
+		DiscontinuitySeq: p.DiscontinuitySeq,
+		Args:             p.Args,
+		Iframe:           p.Iframe,
+		Closed:           p.Closed,
+		MediaType:        p.MediaType,
+		durationAsInt:    p.durationAsInt,
+		keyformat:        p.keyformat,
+		winsize:          p.winsize,
+		capacity:         capacity,
+		ver:              p.ver,
+		Key:              copyKey(keys, p.Key),
+		Keys:             copyKeys(keys, p.Keys),
+		Map:              copyMap(p.Map),
+		W:                p.W,
+	}
+	if p.ServerControl != nil {
+		sc := *p.ServerControl
+		cp.ServerControl = &sc
+	}
+	if p.Start != nil {
+		start := *p.Start
+		cp.Start = &start
+	}
+	cp.Segments = make([]*MediaSegment, capacity)
+	for i, seg := range segs {
+		cp.Segments[i] = seg.clone(keys)
+	}
+	cp.count = len(segs)
+	if capacity > 0 {
+		cp.tail = cp.count % capacity
+	}
+	return cp
+}
+
+// clone returns a copy of the segment, keys are copied once through the
+// keys map so that shared keys stay shared.
+func (seg *MediaSegment) clone(keys map[*Key]*Key) *MediaSegment {
+	cp := *seg
+	cp.Key = copyKey(keys, seg.Key)
+	cp.Keys = copyKeys(keys, seg.Keys)
+	cp.Map = copyMap(seg.Map)
+	if seg.SCTE != nil {
+		scte := *seg.SCTE
+		cp.SCTE = &scte
+	}
+	if seg.DateRanges != nil {
+		cp.DateRanges = append([]*DateRange(nil), seg.DateRanges...)
+	}
+	return &cp
+}
+
+func copyKey(keys map[*Key]*Key, key *Key) *Key {
+	if key == nil {
+		return nil
+	}
+	if cp, ok := keys[key]; ok {
+		return cp
+	}
+	cp := *key
+	keys[key] = &cp
+	return &cp
+}
+
+func copyKeys(keys map[*Key]*Key, set []*Key) []*Key {
+	if set == nil {
+		return nil
+	}
+	cp := make([]*Key, len(set))
+	for i, key := range set {
+		cp[i] = copyKey(keys, key)
+	}
+	return cp
+}
+
+func copyMap(m *Map) *Map {
+	if m == nil {
+		return nil
+	}
+	cp := *m
+	return &cp
+}
+
+// clone returns a copy of the playlist, its variants, alternatives and
+// media playlists. Alternatives shared by variants stay shared.
+func (p *MasterPlaylist) clone() *MasterPlaylist {
+	cp := &MasterPlaylist{
+		Args:          p.Args,
+		CypherVersion: p.CypherVersion,
+		ver:           p.ver,
+	}
+	alts := make(map[*Alternative]*Alternative)
+	for _, v := range p.Variants {
+		vc := *v
+		if v.Chunklist != nil {
+			vc.Chunklist = v.Chunklist.clone()
+		}
+		if v.Alternatives != nil {
+			vc.Alternatives = make([]*Alternative, len(v.Alternatives))
+			for i, alt := range v.Alternatives {
+				if _, ok := alts[alt]; !ok {
+					ac := *alt
+					alts[alt] = &ac
+				}
+				vc.Alternatives[i] = alts[alt]
+			}
+		}
+		cp.Variants = append(cp.Variants, &vc)
+	}
+	return cp
+}
@@ -0,0 +1,113 @@
+package hls
+
+import "errors"
+
+// Concat returns a new closed VOD playlist of the segments of the playlists
+// in order, the first segment of every playlist after the first one
+// starting with a discontinuity; the first segment of the first playlist
+// keeps its own. The playlists are left unchanged.
+// As the result has no default key nor map, the defaults of a playlist are
+// set on its first segment when they differ from the ones in effect, and
+// a key with METHOD=NONE follows encrypted segments where needed. An ad
+// break still open at the end of a playlist is closed on the first segment
+// of the next one. The target duration and the version cover all the
+// segments and playlists, the target duration being the largest of the
+// playlists at least.
+func Concat(playlists ...*MediaPlaylist) (*MediaPlaylist, error) {
+	n := 0
+	for _, p := range playlists {
+		n += p.Count()
+	}
+	if n == 0 {
+		return nil, errors.New("no segments to concatenate")
+	}
+	out, err := NewMediaPlaylist(0, n)
+	if err != nil {
+		return nil, err
+	}
+	out.Closed = true
+	out.MediaType = MediaTypeVOD
+	var (
+		keys []*Key // keys in effect in the result
+		init *Map   // map in effect in the result
+		brk  *adBreak
+		cue  SCTE35Syntax // syntax of the open break
+		seen bool
+	)
+	for _, p := range playlists {
+		cp := p.clone()
+		segs := cp.segments()
+		if len(segs) == 0 {
+			continue
+		}
+		if seen && cp.Iframe != out.Iframe {
+			return nil, errors.New("can not concatenate I-frame and media playlists")
+		}
+		out.Iframe = cp.Iframe
+		checkVersion(&out.ver, cp.ver)
+		if out.TargetDuration < cp.TargetDuration {
+			out.TargetDuration = cp.TargetDuration
+		}
+		for i, seg := range segs {
+			if i == 0 {
+				seg.Discontinuity = seg.Discontinuity || seen
+				if brk != nil && seg.SCTE == nil {
+					brk.end(seg, cue, "", false)
+				}
+				brk = nil
+				// the decoder sets the defaults on the first segment too
+				if seg.Key != nil && sameKeys(keySet(seg.Key, seg.Keys), keys) {
+					seg.Key, seg.Keys = nil, nil
+				} else if seg.Key == nil {
+					if defaults := keySet(cp.Key, cp.Keys); !sameKeys(defaults, keys) {
+						switch {
+						case len(defaults) > 0:
+							seg.Key = defaults[0]
+							if len(defaults) > 1 {
+								seg.Keys = defaults
+							}
+						case len(keys) > 0:
+							seg.Key = &Key{Method: KeyMethodNone}
+						}
+					}
+				}
+				if seg.Map != nil && init != nil && *seg.Map == *init {
+					seg.Map = nil
+				} else if seg.Map == nil {
+					switch {
+					case cp.Map != nil && (init == nil || *cp.Map != *init):
+						seg.Map = cp.Map
+					case cp.Map == nil && init != nil:
+						return nil, errors.New("can not concatenate playlists with and without EXT-X-MAP")
+					}
+				}
+			}
+			if seg.Key != nil {
+				checkKeysVersion(&out.ver, keySet(seg.Key, seg.Keys))
+				if keys = keySet(seg.Key, seg.Keys); seg.Key.Method == KeyMethodNone {
+					keys = nil
+				}
+			}
+			if seg.Map != nil {
+				init = seg.Map
+			}
+			if s := seg.SCTE; s != nil {
+				switch s.cueType() {
+				case SCTE35CueStart:
+					brk, cue = &adBreak{cue: s.Cue, id: s.ID, duration: s.BreakDuration()}, s.Syntax
+				case SCTE35CueMid:
+					if brk == nil {
+						brk, cue = &adBreak{cue: s.Cue, id: s.ID, duration: s.BreakDuration()}, s.Syntax
+					}
+				case SCTE35CueEnd:
+					brk = nil
+				}
+			}
+			if err = out.Append(seg); err != nil {
+				return nil, err
+			}
+		}
+		seen = true
+	}
+	return out, nil
+}
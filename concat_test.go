@@ -0,0 +1,138 @@
+package hls_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/ShevaXu/hls"
+)
+
+func decodeMedia(t *testing.T, playlist string) *hls.MediaPlaylist {
+	t.Helper()
+	p, _ := hls.NewMediaPlaylist(0, 10)
+	if err := p.DecodeFrom(bytes.NewBufferString(playlist), true); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestConcat(t *testing.T) {
+	preroll := decodeMedia(t, `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-TARGETDURATION:5
+#EXT-X-CUE-OUT:10
+#EXTINF:5.000,
+ad1.ts
+#EXT-X-CUE-OUT-CONT:ElapsedTime=5,Duration=10
+#EXTINF:5.000,
+ad2.ts
+#EXT-X-ENDLIST
+`)
+	content := decodeMedia(t, `#EXTM3U
+#EXT-X-VERSION:5
+#EXT-X-TARGETDURATION:7
+#EXT-X-KEY:METHOD=AES-128,URI="content.key",KEYFORMAT="identity"
+#EXTINF:6.500,
+c1.ts
+#EXTINF:6.500,
+c2.ts
+#EXT-X-ENDLIST
+`)
+	postroll := decodeMedia(t, `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-TARGETDURATION:4
+#EXTINF:4.000,
+post.ts
+#EXT-X-ENDLIST
+`)
+	p, err := hls.Concat(preroll, content, postroll)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Count() != 5 || !p.Closed || p.MediaType != hls.MediaTypeVOD || p.Version() != 5 || p.TargetDuration != 7 {
+		t.Fatalf("Unexpected playlist of %d segments, version %d, target %v", p.Count(), p.Version(), p.TargetDuration)
+	}
+	segs := p.Segments[:p.Count()]
+	for i, seg := range segs {
+		if seg.Discontinuity != (i == 2 || i == 4) {
+			t.Errorf("Segment %d: unexpected discontinuity %v", i, seg.Discontinuity)
+		}
+	}
+	if segs[2].SCTE == nil || segs[2].SCTE.CueType != hls.SCTE35CueEnd {
+		t.Errorf("Expected the open break closed on the content, got %+v", segs[2].SCTE)
+	}
+	if segs[2].Key == nil || segs[2].Key.URI != "content.key" || segs[3].Key != nil {
+		t.Errorf("Expected the default key of the content on its first segment, got %+v %+v", segs[2].Key, segs[3].Key)
+	}
+	if segs[4].Key == nil || segs[4].Key.Method != hls.KeyMethodNone {
+		t.Errorf("Expected METHOD=NONE on the post-roll, got %+v", segs[4].Key)
+	}
+	out := p.String()
+	for _, tag := range []string{"#EXT-X-VERSION:5\n", "#EXT-X-TARGETDURATION:7\n", "#EXT-X-CUE-IN\n", "#EXT-X-KEY:METHOD=NONE\n", "#EXT-X-ENDLIST\n"} {
+		if !strings.Contains(out, tag) {
+			t.Errorf("Expected %q in\n%s", tag, out)
+		}
+	}
+	// the sources are unchanged
+	if content.Segments[0].Discontinuity || postroll.Segments[0].Key != nil || preroll.Segments[1].SCTE.CueType != hls.SCTE35CueMid {
+		t.Error("Source playlists modified")
+	}
+}
+
+func TestConcatMaps(t *testing.T) {
+	fmp4 := `#EXTM3U
+#EXT-X-VERSION:6
+#EXT-X-TARGETDURATION:4
+#EXT-X-MAP:URI="init.mp4"
+#EXTINF:4.000,
+a.mp4
+#EXT-X-ENDLIST
+`
+	ts := `#EXTM3U
+#EXT-X-TARGETDURATION:4
+#EXTINF:4.000,
+a.ts
+#EXT-X-ENDLIST
+`
+	p, err := hls.Concat(decodeMedia(t, ts), decodeMedia(t, fmp4), decodeMedia(t, fmp4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Segments[1].Map == nil || p.Segments[1].Map.URI != "init.mp4" || p.Segments[2].Map != nil {
+		t.Errorf("Expected a single map, got %+v %+v", p.Segments[1].Map, p.Segments[2].Map)
+	}
+	if _, err := hls.Concat(decodeMedia(t, fmp4), decodeMedia(t, ts)); err == nil {
+		t.Error("Expected an error for segments without map after a map")
+	}
+	if _, err := hls.Concat(); err == nil {
+		t.Error("Expected an error without segments")
+	}
+}
+
+func TestConcatFirstDiscontinuity(t *testing.T) {
+	first := decodeMedia(t, `#EXTM3U
+#EXT-X-TARGETDURATION:10
+#EXT-X-DISCONTINUITY
+#EXTINF:4.000,
+a.ts
+#EXT-X-ENDLIST
+`)
+	second := decodeMedia(t, `#EXTM3U
+#EXT-X-TARGETDURATION:6
+#EXTINF:4.000,
+b.ts
+#EXT-X-ENDLIST
+`)
+	p, err := hls.Concat(first, second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.Segments[0].Discontinuity || !p.Segments[1].Discontinuity {
+		t.Errorf("Expected both segments to start with a discontinuity, got %v %v", p.Segments[0].Discontinuity, p.Segments[1].Discontinuity)
+	}
+	// the declared target duration is kept over the longest segment
+	if p.TargetDuration != 10 || !strings.Contains(p.String(), "#EXT-X-TARGETDURATION:10\n") {
+		t.Errorf("Expected target duration 10, got %v", p.TargetDuration)
+	}
+}
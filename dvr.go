@@ -0,0 +1,47 @@
+package hls
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+// ToVOD returns a copy of the live or event playlist as a closed VOD
+// playlist of all its segments, without window nor EXT-X-SERVER-CONTROL.
+// The media and discontinuity sequence numbers are kept.
+func (p *MediaPlaylist) ToVOD() *MediaPlaylist {
+	cp := p.clone()
+	cp.winsize = 0
+	cp.Closed = true
+	cp.MediaType = MediaTypeVOD
+	cp.ServerControl = nil
+	return cp
+}
+
+// DVR returns a live playlist of the last segments of the playlist, e.g.
+// of a full event playlist, covering the window duration rather than a
+// number of segments as winsize does. Its window and its capacity are the
+// number of these segments: Slide moves the window along as segments are
+// added, counting the media and discontinuity sequence numbers of the
+// segments leaving it, while Append fails with ErrPlaylistFull. The media
+// and discontinuity sequence numbers are those of the first segment in the
+// source, which gets the key and the map in effect for it and its program
+// date time. MediaType is zero, no EXT-X-PLAYLIST-TYPE, as segments leave
+// the playlist.
+func (p *MediaPlaylist) DVR(window time.Duration) (*MediaPlaylist, error) {
+	if window <= 0 {
+		return nil, errors.New("invalid DVR window")
+	}
+	x := p.timeIndex()
+	if len(x.segs) == 0 {
+		return nil, errors.New("playlist is empty")
+	}
+	// the first segment ending inside the window
+	start := x.end - window
+	from := sort.Search(len(x.segs), func(i int) bool { return x.offsets[i]+seconds(x.segs[i].Duration) > start })
+	cp := p.slice(from, len(x.segs))
+	cp.winsize = cp.count
+	cp.Closed = false
+	cp.MediaType = 0 // live
+	return cp, nil
+}
@@ -0,0 +1,109 @@
+package hls_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ShevaXu/hls"
+)
+
+const eventPlaylist = `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-MEDIA-SEQUENCE:0
+#EXT-X-TARGETDURATION:6
+#EXT-X-PLAYLIST-TYPE:EVENT
+#EXT-X-SERVER-CONTROL:CAN-SKIP-UNTIL=36
+#EXT-X-KEY:METHOD=AES-128,URI="key1"
+#EXT-X-PROGRAM-DATE-TIME:2020-01-01T00:00:00Z
+#EXTINF:6.000,
+s0.ts
+#EXT-X-DISCONTINUITY
+#EXTINF:6.000,
+s1.ts
+#EXTINF:6.000,
+s2.ts
+#EXT-X-DISCONTINUITY
+#EXTINF:6.000,
+s3.ts
+#EXTINF:6.000,
+s4.ts
+#EXTINF:6.000,
+s5.ts
+`
+
+func TestToVOD(t *testing.T) {
+	p := decodeMedia(t, eventPlaylist)
+	v := p.ToVOD()
+	if !v.Closed || v.MediaType != hls.MediaTypeVOD || v.WinSize() != 0 || v.ServerControl != nil || v.Count() != 6 {
+		t.Fatalf("Unexpected VOD playlist:\n%s", v)
+	}
+	out := v.String()
+	if !strings.Contains(out, "#EXT-X-PLAYLIST-TYPE:VOD\n") || !strings.HasSuffix(out, "#EXT-X-ENDLIST\n") {
+		t.Errorf("Unexpected VOD playlist:\n%s", out)
+	}
+	if p.Closed || p.MediaType != hls.MediaTypeEvent || p.ServerControl == nil {
+		t.Error("Source playlist modified")
+	}
+}
+
+func TestDVR(t *testing.T) {
+	p := decodeMedia(t, eventPlaylist)
+	d, err := p.DVR(15 * time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// s3 ends 18s before the end, the window starts inside it; the
+	// discontinuity of s1 is counted, s3 keeps its own
+	if d.Count() != 3 || d.WinSize() != 3 || d.SeqNo != 3 || d.DiscontinuitySeq != 1 || d.Closed || d.MediaType != 0 {
+		t.Fatalf("Unexpected DVR window %d segments from %d/%d:\n%s", d.Count(), d.SeqNo, d.DiscontinuitySeq, d)
+	}
+	first := d.Segments[0]
+	if first.URI != "s3.ts" || !first.Discontinuity || first.Key == nil || first.Key.URI != "key1" {
+		t.Errorf("Unexpected first segment %+v", first)
+	}
+	if !first.ProgramDateTime.Equal(time.Date(2020, 1, 1, 0, 0, 18, 0, time.UTC)) {
+		t.Errorf("Unexpected program date time %v", first.ProgramDateTime)
+	}
+	out := d.String()
+	for _, tag := range []string{"#EXT-X-MEDIA-SEQUENCE:3\n", "#EXT-X-DISCONTINUITY-SEQUENCE:1\n"} {
+		if !strings.Contains(out, tag) {
+			t.Errorf("Expected %q in\n%s", tag, out)
+		}
+	}
+	if strings.Contains(out, "#EXT-X-PLAYLIST-TYPE") || strings.Contains(out, "#EXT-X-ENDLIST") {
+		t.Errorf("Expected a live playlist:\n%s", out)
+	}
+	// the window slides
+	if _, err = d.Slide(&hls.MediaSegment{URI: "s6.ts", Duration: 6}); err != nil {
+		t.Fatal(err)
+	}
+	if d.SeqNo != 4 || d.Count() != 3 || d.DiscontinuitySeq != 2 {
+		t.Errorf("Expected the window to slide past s3, got %d segments from %d/%d", d.Count(), d.SeqNo, d.DiscontinuitySeq)
+	}
+	for i := 7; i < 12; i++ {
+		if _, err = d.Slide(&hls.MediaSegment{URI: fmt.Sprintf("s%d.ts", i), Duration: 6, Discontinuity: i == 8}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// s4 to s8 left the window, with the discontinuity of s8
+	if d.SeqNo != 9 || d.Count() != 3 || d.DiscontinuitySeq != 3 {
+		t.Errorf("Expected s9 to s11 in timeline 3, got %d segments from %d/%d", d.Count(), d.SeqNo, d.DiscontinuitySeq)
+	}
+	if out := d.String(); !strings.Contains(out, "#EXT-X-MEDIA-SEQUENCE:9\n#EXT-X-DISCONTINUITY-SEQUENCE:3\n") ||
+		!strings.Contains(out, "s9.ts\n") || strings.Contains(out, "s8.ts") {
+		t.Errorf("Unexpected DVR window:\n%s", out)
+	}
+	if err = d.Append(&hls.MediaSegment{URI: "s12.ts", Duration: 6}); err != hls.ErrPlaylistFull {
+		t.Errorf("Expected %v past the window, got %v", hls.ErrPlaylistFull, err)
+	}
+
+	// a window longer than the playlist keeps all the segments
+	if d, _ = p.DVR(time.Hour); d.Count() != 6 || d.SeqNo != 0 || d.DiscontinuitySeq != 0 {
+		t.Errorf("Expected the whole playlist, got %d segments from %d/%d", d.Count(), d.SeqNo, d.DiscontinuitySeq)
+	}
+	if _, err = p.DVR(0); err == nil {
+		t.Error("Expected an error for an empty window")
+	}
+}
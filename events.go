@@ -0,0 +1,94 @@
+package hls
+
+import "sync"
+
+// EventType is the kind of change of a media playlist.
+type EventType int
+
+// Changes published to the subscribers of a media playlist.
+// Slide publishes EventRemove, if the window is full, then EventAppend.
+const (
+	EventAppend EventType = iota + 1
+	EventRemove
+	EventClose
+)
+
+// String returns the name of the event type.
+func (t EventType) String() string {
+	switch t {
+	case EventAppend:
+		return "append"
+	case EventRemove:
+		return "remove"
+	case EventClose:
+		return "close"
+	}
+	return "unknown"
+}
+
+// Event is a change of a media playlist delivered to its subscribers.
+type Event struct {
+	Type    EventType
+	Segment *MediaSegment // appended or removed segment, nil for EventClose
+	SeqID   int           // media sequence number of Segment
+	SeqNo   int           // EXT-X-MEDIA-SEQUENCE after the change
+	Missed  int           // events dropped before this one as the subscriber lagged behind
+}
+
+// subscription is a subscriber channel.
+type subscription struct {
+	ch     chan Event
+	missed int
+}
+
+// eventHub fans out events to the subscribers.
+type eventHub struct {
+	mu   sync.Mutex
+	subs map[*subscription]struct{}
+}
+
+// Subscribe returns a channel receiving the changes of the playlist and a
+// function to cancel the subscription, which closes the channel.
+// Publishing never blocks: events are dropped while the channel buffer of
+// the given size is full, and the next delivered event counts them in Missed.
+// Subscribe must not be called concurrently with changes of the playlist,
+// see LivePlaylist.Subscribe.
+func (p *MediaPlaylist) Subscribe(buffer int) (<-chan Event, func()) {
+	if p.events == nil {
+		p.events = &eventHub{subs: make(map[*subscription]struct{})}
+	}
+	h := p.events
+	s := &subscription{ch: make(chan Event, buffer)}
+	h.mu.Lock()
+	h.subs[s] = struct{}{}
+	h.mu.Unlock()
+	var once sync.Once
+	return s.ch, func() {
+		once.Do(func() {
+			h.mu.Lock()
+			delete(h.subs, s)
+			h.mu.Unlock()
+			close(s.ch)
+		})
+	}
+}
+
+// publish delivers an event to the subscribers of the playlist.
+func (p *MediaPlaylist) publish(t EventType, seg *MediaSegment, seqID int) {
+	if p.events == nil {
+		return
+	}
+	ev := Event{Type: t, Segment: seg, SeqID: seqID, SeqNo: p.SeqNo}
+	h := p.events
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for s := range h.subs {
+		ev.Missed = s.missed
+		select {
+		case s.ch <- ev:
+			s.missed = 0
+		default:
+			s.missed++
+		}
+	}
+}
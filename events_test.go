@@ -0,0 +1,80 @@
+package hls_test
+
+import (
+	"testing"
+
+	"github.com/ShevaXu/hls"
+)
+
+func TestSubscribe(t *testing.T) {
+	p, err := hls.NewMediaPlaylist(2, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events, cancel := p.Subscribe(10)
+	for _, uri := range []string{"a.ts", "b.ts", "c.ts"} {
+		if _, err = p.Slide(hls.QuickSegment(uri, "", 4)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	p.Close()
+	expected := []hls.Event{
+		{Type: hls.EventAppend, SeqID: 0, SeqNo: 0},
+		{Type: hls.EventAppend, SeqID: 1, SeqNo: 0},
+		{Type: hls.EventRemove, SeqID: 0, SeqNo: 1},
+		{Type: hls.EventAppend, SeqID: 2, SeqNo: 1},
+		{Type: hls.EventClose, SeqNo: 1},
+	}
+	uris := []string{"a.ts", "b.ts", "a.ts", "c.ts", ""}
+	for i, exp := range expected {
+		ev := <-events
+		uri := ""
+		if ev.Segment != nil {
+			uri = ev.Segment.URI
+		}
+		ev.Segment = nil
+		if ev != exp || uri != uris[i] {
+			t.Errorf("Event %d: expected %+v %s, got %+v %s", i, exp, uris[i], ev, uri)
+		}
+	}
+	cancel()
+	cancel()
+	if _, ok := <-events; ok {
+		t.Error("Expected channel closed after cancel")
+	}
+	if err = p.Append(hls.QuickSegment("d.ts", "", 4)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSubscribeMissed(t *testing.T) {
+	p, err := hls.NewMediaPlaylist(0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events, cancel := p.Subscribe(1)
+	defer cancel()
+	for i := 0; i < 4; i++ {
+		p.Append(hls.QuickSegment("seg.ts", "", 4))
+	}
+	if ev := <-events; ev.SeqID != 0 || ev.Missed != 0 {
+		t.Errorf("Unexpected first event %+v", ev)
+	}
+	p.Append(hls.QuickSegment("seg.ts", "", 4))
+	if ev := <-events; ev.SeqID != 4 || ev.Missed != 3 {
+		t.Errorf("Expected event 4 after 3 missed, got %+v", ev)
+	}
+}
+
+func TestLivePlaylistSubscribe(t *testing.T) {
+	l, err := hls.NewLivePlaylist(3, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events, cancel := l.Subscribe(1)
+	defer cancel()
+	go l.Append(hls.QuickSegment("a.ts", "", 4))
+	if ev := <-events; ev.Type != hls.EventAppend || ev.Segment.URI != "a.ts" {
+		t.Errorf("Unexpected event %+v", ev)
+	}
+}
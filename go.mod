@@ -1 +1,3 @@
 module github.com/ShevaXu/hls
+
+go 1.16
@@ -0,0 +1,260 @@
+// Package handler serves master and media playlists over HTTP, with the
+// blocking playlist reload and the playlist delta updates of Low-Latency
+// HLS (section 6.2.5 of draft-pantos-hls-rfc8216bis).
+//
+// Media playlists are served from LivePlaylist wrappers, so that a packager
+// can update them while the handler serves them:
+//
+//	live, _ := hls.NewLivePlaylist(6, 12)
+//	h := handler.New()
+//	h.HandleMedia("/live/index.m3u8", live)
+//	go http.ListenAndServe(":8080", h)
+//	live.Slide(segment) // wakes up the blocked requests
+package handler
+
+import (
+	"compress/gzip"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ShevaXu/hls"
+)
+
+// ContentType is the media type of playlists, see section 4 of RFC 8216.
+const ContentType = "application/vnd.apple.mpegurl"
+
+// Query parameters of the delivery directives.
+const (
+	QueryMSN  = "_HLS_msn"
+	QueryPart = "_HLS_part"
+	QuerySkip = "_HLS_skip"
+)
+
+// MinBlockTimeout is the least default timeout of blocking playlist reloads.
+const MinBlockTimeout = time.Second
+
+// Handler serves playlists registered by path.
+type Handler struct {
+	// BlockTimeout is the maximum time a blocking playlist reload waits
+	// for the requested segment before responding with 503.
+	// It defaults to three times the target duration of the playlist,
+	// and to MinBlockTimeout at least, e.g. while the playlist has no
+	// target duration yet.
+	BlockTimeout time.Duration
+
+	mu      sync.RWMutex
+	masters map[string]*master
+	medias  map[string]*hls.LivePlaylist
+}
+
+// master guards a master playlist as Encode updates its cache.
+type master struct {
+	mu sync.Mutex
+	p  *hls.MasterPlaylist
+}
+
+// New creates a handler without playlists.
+func New() *Handler {
+	return &Handler{
+		masters: make(map[string]*master),
+		medias:  make(map[string]*hls.LivePlaylist),
+	}
+}
+
+// HandleMaster serves the master playlist at the URL path.
+// The playlist must not be changed while it is served.
+func (h *Handler) HandleMaster(path string, p *hls.MasterPlaylist) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.masters[path] = &master{p: p}
+}
+
+// HandleMedia serves the media playlist at the URL path.
+func (h *Handler) HandleMedia(path string, p *hls.LivePlaylist) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.medias[path] = p
+}
+
+// Remove stops serving the playlist at the URL path.
+func (h *Handler) Remove(path string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.masters, path)
+	delete(h.medias, path)
+}
+
+// ServeHTTP serves the playlist registered at the path of the request.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	h.mu.RLock()
+	m := h.masters[r.URL.Path]
+	l := h.medias[r.URL.Path]
+	h.mu.RUnlock()
+	switch {
+	case m != nil:
+		m.mu.Lock()
+		body := append([]byte(nil), m.p.Encode().Bytes()...)
+		m.mu.Unlock()
+		write(w, r, body, "max-age=60")
+	case l != nil:
+		h.serveMedia(w, r, l)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// serveMedia serves a media playlist, blocking until the segment requested
+// by _HLS_msn is available.
+func (h *Handler) serveMedia(w http.ResponseWriter, r *http.Request, l *hls.LivePlaylist) {
+	q := r.URL.Query()
+	msn := -1
+	if v := q.Get(QueryMSN); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			http.Error(w, "invalid "+QueryMSN, http.StatusBadRequest)
+			return
+		}
+		msn = n
+	}
+	if v := q.Get(QueryPart); v != "" {
+		// parts are not modelled, the whole segment satisfies any of its parts
+		if n, err := strconv.Atoi(v); err != nil || n < 0 || msn < 0 {
+			http.Error(w, "invalid "+QueryPart, http.StatusBadRequest)
+			return
+		}
+	}
+	if msn >= 0 {
+		if status := h.block(r, l, msn); status != http.StatusOK {
+			http.Error(w, http.StatusText(status), status)
+			return
+		}
+	}
+	var (
+		body   []byte
+		closed bool
+		target float64
+	)
+	l.View(func(p *hls.MediaPlaylist) {
+		closed = p.Closed || p.MediaType == hls.MediaTypeVOD
+		target = p.TargetDuration
+	})
+	if skip := q.Get(QuerySkip); skip == "YES" || skip == "v2" {
+		body = l.DeltaBytes(skip == "v2")
+	} else {
+		body = l.Bytes()
+	}
+	write(w, r, body, cacheControl(closed, target, msn >= 0))
+}
+
+// block waits until the segment msn is in the playlist or the playlist is
+// closed, it returns the HTTP status of the response.
+func (h *Handler) block(r *http.Request, l *hls.LivePlaylist, msn int) int {
+	var timer *time.Timer
+	for {
+		changed := l.Changed()
+		var (
+			last   int
+			closed bool
+			target float64
+		)
+		l.View(func(p *hls.MediaPlaylist) {
+			last = p.SeqNo + p.Count() - 1
+			closed = p.Closed
+			target = p.TargetDuration
+		})
+		if closed || msn <= last {
+			return http.StatusOK
+		}
+		// the server SHOULD reject requests for more than two segments ahead
+		if msn > last+2 {
+			return http.StatusBadRequest
+		}
+		if timer == nil {
+			timeout := h.BlockTimeout
+			if timeout == 0 {
+				if timeout = time.Duration(3 * target * float64(time.Second)); timeout < MinBlockTimeout {
+					timeout = MinBlockTimeout
+				}
+			}
+			timer = time.NewTimer(timeout)
+			defer timer.Stop()
+		}
+		select {
+		case <-changed:
+		case <-timer.C:
+			return http.StatusServiceUnavailable
+		case <-r.Context().Done():
+			return http.StatusServiceUnavailable
+		}
+	}
+}
+
+// cacheControl returns the Cache-Control of a media playlist response.
+// Closed playlists do not change anymore. Blocking reload responses have
+// unique URLs and are cached for six target durations, other live responses
+// for half a target duration.
+func cacheControl(closed bool, target float64, blocking bool) string {
+	var age int
+	switch {
+	case closed:
+		age = 86400
+	case blocking:
+		age = int(6 * target)
+	default:
+		age = int(target / 2)
+	}
+	if age < 1 {
+		age = 1
+	}
+	return "max-age=" + strconv.Itoa(age)
+}
+
+// write writes the playlist, gzipped if the client accepts it.
+func write(w http.ResponseWriter, r *http.Request, body []byte, cache string) {
+	header := w.Header()
+	header.Set("Content-Type", ContentType)
+	header.Set("Cache-Control", cache)
+	header.Add("Vary", "Accept-Encoding")
+	if !acceptsGzip(r) {
+		header.Set("Content-Length", strconv.Itoa(len(body)))
+		if r.Method != http.MethodHead {
+			w.Write(body)
+		}
+		return
+	}
+	header.Set("Content-Encoding", "gzip")
+	if r.Method == http.MethodHead {
+		return
+	}
+	gz := gzip.NewWriter(w)
+	gz.Write(body)
+	gz.Close()
+}
+
+// acceptsGzip reports whether the request accepts gzip content coding.
+func acceptsGzip(r *http.Request) bool {
+	for _, v := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		params := strings.Split(v, ";")
+		if strings.TrimSpace(params[0]) != "gzip" {
+			continue
+		}
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if q, err := strconv.ParseFloat(param[2:], 64); err == nil && q == 0 {
+					return false
+				}
+			}
+		}
+		return true
+	}
+	return false
+}
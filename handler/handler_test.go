@@ -0,0 +1,216 @@
+package handler_test
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ShevaXu/hls"
+	"github.com/ShevaXu/hls/handler"
+)
+
+func newLive(t *testing.T, segments int) *hls.LivePlaylist {
+	l, err := hls.NewLivePlaylist(6, 12)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Update(func(p *hls.MediaPlaylist) error {
+		p.ServerControl = &hls.ServerControl{CanSkipUntil: 12, CanBlockReload: true}
+		return nil
+	})
+	for i := 0; i < segments; i++ {
+		if _, err = l.Slide(hls.QuickSegment(fmt.Sprintf("seg%d.ts", i), "", 2)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return l
+}
+
+func get(t *testing.T, h http.Handler, target string, header map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestServePlaylists(t *testing.T) {
+	h := handler.New()
+	m := hls.NewMasterPlaylist()
+	m.Append("low/index.m3u8", nil, hls.VariantParams{Bandwidth: 500000})
+	h.HandleMaster("/master.m3u8", m)
+	live := newLive(t, 3)
+	h.HandleMedia("/low/index.m3u8", live)
+
+	rec := get(t, h, "/master.m3u8", nil)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "low/index.m3u8") {
+		t.Fatalf("Unexpected master response %d:\n%s", rec.Code, rec.Body)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != handler.ContentType {
+		t.Errorf("Expected content type %s, got %s", handler.ContentType, ct)
+	}
+	rec = get(t, h, "/low/index.m3u8", nil)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "seg2.ts") {
+		t.Fatalf("Unexpected media response %d:\n%s", rec.Code, rec.Body)
+	}
+	if cc := rec.Header().Get("Cache-Control"); cc != "max-age=1" {
+		t.Errorf("Expected max-age=1 for live playlist, got %s", cc)
+	}
+	live.Close()
+	if cc := get(t, h, "/low/index.m3u8", nil).Header().Get("Cache-Control"); cc != "max-age=86400" {
+		t.Errorf("Expected max-age=86400 for closed playlist, got %s", cc)
+	}
+	if rec = get(t, h, "/missing.m3u8", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404, got %d", rec.Code)
+	}
+}
+
+func TestServeGzip(t *testing.T) {
+	h := handler.New()
+	live := newLive(t, 3)
+	h.HandleMedia("/index.m3u8", live)
+	rec := get(t, h, "/index.m3u8", map[string]string{"Accept-Encoding": "br, gzip"})
+	if rec.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatal("Expected gzip content encoding")
+	}
+	gz, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := ioutil.ReadAll(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != live.String() {
+		t.Errorf("Unexpected body:\n%s", body)
+	}
+	if rec = get(t, h, "/index.m3u8", map[string]string{"Accept-Encoding": "gzip;q=0"}); rec.Header().Get("Content-Encoding") != "" {
+		t.Error("Expected no content encoding for gzip;q=0")
+	}
+}
+
+func TestServeDelta(t *testing.T) {
+	h := handler.New()
+	h.HandleMedia("/index.m3u8", newLive(t, 10))
+	body := get(t, h, "/index.m3u8?_HLS_skip=YES", nil).Body.String()
+	// 6 segments of 2s in the window, the last 12s can not be skipped
+	if !strings.Contains(body, "#EXT-X-SERVER-CONTROL:CAN-SKIP-UNTIL=12,CAN-BLOCK-RELOAD=YES\n") {
+		t.Errorf("Expected EXT-X-SERVER-CONTROL in\n%s", body)
+	}
+	if strings.Contains(body, "#EXT-X-SKIP") {
+		t.Errorf("Expected nothing to skip in\n%s", body)
+	}
+
+	l, _ := hls.NewLivePlaylist(6, 12)
+	l.Update(func(p *hls.MediaPlaylist) error {
+		p.ServerControl = &hls.ServerControl{CanSkipUntil: 6}
+		return nil
+	})
+	for i := 0; i < 10; i++ {
+		l.Slide(hls.QuickSegment(fmt.Sprintf("seg%d.ts", i), "", 2))
+	}
+	h.HandleMedia("/skip.m3u8", l)
+	body = get(t, h, "/skip.m3u8?_HLS_skip=YES", nil).Body.String()
+	if !strings.Contains(body, "#EXT-X-VERSION:9\n") {
+		t.Errorf("Expected version 9 for EXT-X-SKIP in\n%s", body)
+	}
+	if !strings.Contains(body, "#EXT-X-SKIP:SKIPPED-SEGMENTS=3\n#EXTINF:2.000,\nseg7.ts\n") || strings.Contains(body, "seg6.ts") {
+		t.Errorf("Unexpected delta update\n%s", body)
+	}
+}
+
+func TestDeltaUpdateSkipDateRanges(t *testing.T) {
+	h := handler.New()
+	l, _ := hls.NewLivePlaylist(6, 12)
+	l.Update(func(p *hls.MediaPlaylist) error {
+		p.ServerControl = &hls.ServerControl{CanSkipUntil: 6, CanSkipDateRanges: true}
+		return nil
+	})
+	for i := 0; i < 10; i++ {
+		seg := hls.QuickSegment(fmt.Sprintf("seg%d.ts", i), "", 2)
+		if i == 4 { // the first segment of the window, skipped
+			seg.DateRanges = []*hls.DateRange{{ID: "ad", StartDate: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}}
+		}
+		l.Slide(seg)
+	}
+	h.HandleMedia("/skip.m3u8", l)
+
+	body := get(t, h, "/skip.m3u8?_HLS_skip=YES", nil).Body.String()
+	if !strings.Contains(body, "#EXT-X-DATERANGE:ID=\"ad\"") || strings.Contains(body, "RECENTLY-REMOVED-DATERANGES") {
+		t.Errorf("Expected the date range of the skipped segments in\n%s", body)
+	}
+	body = get(t, h, "/skip.m3u8?_HLS_skip=v2", nil).Body.String()
+	if !strings.Contains(body, "#EXT-X-VERSION:10\n") || strings.Contains(body, "#EXT-X-DATERANGE") {
+		t.Errorf("Expected the date ranges to be skipped in\n%s", body)
+	}
+	if !strings.Contains(body, "#EXT-X-SKIP:SKIPPED-SEGMENTS=3,RECENTLY-REMOVED-DATERANGES=\"\"\n") {
+		t.Errorf("Unexpected delta update\n%s", body)
+	}
+}
+
+func TestBlockingReload(t *testing.T) {
+	h := handler.New()
+	h.BlockTimeout = time.Second
+	live := newLive(t, 3) // sequence numbers 0 to 2
+	h.HandleMedia("/index.m3u8", live)
+
+	if rec := get(t, h, "/index.m3u8?_HLS_msn=2", nil); rec.Code != http.StatusOK {
+		t.Errorf("Expected 200 for available segment, got %d", rec.Code)
+	}
+	if rec := get(t, h, "/index.m3u8?_HLS_msn=5", nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for segment too far ahead, got %d", rec.Code)
+	}
+	if rec := get(t, h, "/index.m3u8?_HLS_part=1", nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for _HLS_part without _HLS_msn, got %d", rec.Code)
+	}
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		done <- get(t, h, "/index.m3u8?_HLS_msn=3&_HLS_part=0", nil)
+	}()
+	select {
+	case <-done:
+		t.Fatal("Expected the request to block")
+	case <-time.After(50 * time.Millisecond):
+	}
+	live.Slide(hls.QuickSegment("seg3.ts", "", 2))
+	rec := <-done
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "seg3.ts") {
+		t.Errorf("Unexpected response %d:\n%s", rec.Code, rec.Body)
+	}
+	if cc := rec.Header().Get("Cache-Control"); cc != "max-age=12" {
+		t.Errorf("Expected max-age=12 for blocking reload, got %s", cc)
+	}
+
+	h.BlockTimeout = 20 * time.Millisecond
+	if rec = get(t, h, "/index.m3u8?_HLS_msn=4", nil); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 after timeout, got %d", rec.Code)
+	}
+}
+
+func TestBlockingReloadMinTimeout(t *testing.T) {
+	h := handler.New()
+	l, _ := hls.NewLivePlaylist(3, 5) // no segment, no target duration yet
+	h.HandleMedia("/index.m3u8", l)
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		done <- get(t, h, "/index.m3u8?_HLS_msn=0", nil)
+	}()
+	select {
+	case rec := <-done:
+		t.Fatalf("Expected the request to block, got %d", rec.Code)
+	case <-time.After(100 * time.Millisecond):
+	}
+	l.Append(hls.QuickSegment("seg0.ts", "", 2))
+	if rec := <-done; rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "seg0.ts") {
+		t.Errorf("Unexpected response %d:\n%s", rec.Code, rec.Body)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/ShevaXu/hls"
 )
@@ -40,3 +41,14 @@ func BenchmarkEncodeMediaPlaylist(b *testing.B) {
 		_ = p.Encode() // disregard output
 	}
 }
+
+func BenchmarkSegmentAt(b *testing.B) {
+	p, _ := hls.NewMediaPlaylist(0, 100000)
+	for i := 0; i < 100000; i++ {
+		p.Append(&hls.MediaSegment{URI: "seg.ts", Duration: 6})
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		p.SegmentAt(time.Duration(i%600000) * time.Second)
+	}
+}
@@ -0,0 +1,172 @@
+package hls
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// KeyProvider generates encryption keys for a KeyRotator.
+type KeyProvider interface {
+	// NewKey returns the EXT-X-KEY for the segments starting at the media
+	// sequence number seqID, along with the key material it refers to.
+	NewKey(seqID int) (key *Key, material []byte, err error)
+}
+
+// KeyProviderFunc adapts a function to the KeyProvider interface.
+type KeyProviderFunc func(seqID int) (*Key, []byte, error)
+
+// NewKey calls f(seqID).
+func (f KeyProviderFunc) NewKey(seqID int) (*Key, []byte, error) {
+	return f(seqID)
+}
+
+// RandomKeys returns a KeyProvider of AES-128 keys with random material
+// and IV. The URI of a key is uriFormat formatted with the media sequence
+// number of its first segment, e.g. "https://keys.example.com/%d.key".
+func RandomKeys(uriFormat string) KeyProvider {
+	return KeyProviderFunc(func(seqID int) (*Key, []byte, error) {
+		buf := make([]byte, 32)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, nil, err
+		}
+		key := &Key{
+			Method: KeyMethodAES128,
+			URI:    fmt.Sprintf(uriFormat, seqID),
+			IV:     "0x" + strings.ToUpper(hex.EncodeToString(buf[16:])),
+		}
+		return key, buf[:16], nil
+	})
+}
+
+// KeyRotator changes the encryption key of a live playlist every given
+// number of segments or seconds, whichever comes first. Attached to a
+// MediaPlaylist with SetKeyRotator, it sets the Key of the appended
+// segments at the rotation boundaries, so that Encode writes EXT-X-KEY
+// only where the key changes.
+type KeyRotator struct {
+	Provider KeyProvider
+	Segments int     // rotate every Segments segments, zero disables
+	Period   float64 // rotate every Period seconds, zero disables
+	key      *Key
+	material []byte
+	segments int     // segments encrypted with the current key
+	elapsed  float64 // duration of segments encrypted with the current key
+}
+
+// NewKeyRotator creates a key rotator with the provider which rotates
+// keys every segments segments or period seconds.
+func NewKeyRotator(provider KeyProvider, segments int, period float64) *KeyRotator {
+	return &KeyRotator{Provider: provider, Segments: segments, Period: period}
+}
+
+// Key returns the current key and its material, nil before the first
+// segment is appended.
+func (r *KeyRotator) Key() (*Key, []byte) {
+	return r.key, r.material
+}
+
+// due reports whether the next segment needs a new key.
+func (r *KeyRotator) due() bool {
+	return r.key == nil ||
+		r.Segments > 0 && r.segments >= r.Segments ||
+		r.Period > 0 && r.elapsed >= r.Period-0.001
+}
+
+// SetKeyRotator attaches the key rotator to the playlist, nil detaches it.
+// Every appended segment is then encrypted with the key of the rotator,
+// a new one being requested from its provider when a rotation is due.
+// Segments which already have a Key are left as is and restart the period.
+// When the segment carrying the EXT-X-KEY tag is removed, its key moves
+// to the new first segment.
+func (p *MediaPlaylist) SetKeyRotator(r *KeyRotator) {
+	p.keyRotator = r
+}
+
+// rotateKey sets the key of the rotator on an appended segment with the
+// media sequence number seqID if a rotation is due.
+func (p *MediaPlaylist) rotateKey(seg *MediaSegment, seqID int) error {
+	r := p.keyRotator
+	switch {
+	case seg.Key != nil:
+		r.key, r.material = seg.Key, nil
+		r.segments, r.elapsed = 0, 0
+	case r.due():
+		if r.Provider == nil {
+			return errors.New("key rotator has no provider")
+		}
+		key, material, err := r.Provider.NewKey(seqID)
+		if err != nil {
+			return err
+		}
+		if err = key.Validate(); err != nil {
+			return err
+		}
+		checkKeysVersion(&p.ver, []*Key{key})
+		seg.Key, seg.Keys = key, nil
+		r.key, r.material = key, material
+		r.segments, r.elapsed = 0, 0
+	}
+	r.segments++
+	r.elapsed += seg.Duration
+	return nil
+}
+
+// carryKey moves the keys of a removed segment to the new first segment,
+// which would be left unencrypted otherwise.
+func (p *MediaPlaylist) carryKey(removed *MediaSegment) {
+	if removed == nil || removed.Key == nil || p.count == 0 {
+		return
+	}
+	if head := p.Segments[p.head]; head != nil && head.Key == nil {
+		head.Key, head.Keys = removed.Key, removed.Keys
+	}
+}
+
+// Validate checks the method of the key, the presence of URI and the format
+// of IV, which must be a 128-bit hexadecimal-sequence with 0x prefix.
+// Keys are validated when set, when provided to a KeyRotator and when
+// decoded in strict mode.
+func (k *Key) Validate() error {
+	switch k.Method {
+	case KeyMethodNone:
+		if k.URI != "" || k.IV != "" || k.Keyformat != "" || k.Keyformatversions != "" {
+			return errors.New("EXT-X-KEY with METHOD=NONE must not have other attributes")
+		}
+		return nil
+	case KeyMethodAES128, KeyMethodSampleAES, KeyMethodSampleAESCTR:
+	default:
+		return fmt.Errorf("unknown EXT-X-KEY method %q", k.Method)
+	}
+	if k.URI == "" {
+		return fmt.Errorf("EXT-X-KEY with METHOD=%s requires URI", k.Method)
+	}
+	if k.IV != "" && !validIV(k.IV) {
+		return fmt.Errorf("EXT-X-KEY IV %q is not a 128-bit hexadecimal-sequence", k.IV)
+	}
+	return nil
+}
+
+// validateKeys checks a non-empty set of keys.
+func validateKeys(keys []*Key) error {
+	if len(keys) == 0 {
+		return errors.New("no keys")
+	}
+	for _, k := range keys {
+		if err := k.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validIV reports whether iv is 0x followed by 32 hexadecimal digits.
+func validIV(iv string) bool {
+	if len(iv) != 34 || iv[0] != '0' || (iv[1] != 'x' && iv[1] != 'X') {
+		return false
+	}
+	_, err := hex.DecodeString(iv[2:])
+	return err == nil
+}
@@ -0,0 +1,202 @@
+package hls_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/ShevaXu/hls"
+)
+
+func seqKeys() hls.KeyProvider {
+	return hls.KeyProviderFunc(func(seqID int) (*hls.Key, []byte, error) {
+		return &hls.Key{Method: "AES-128", URI: fmt.Sprintf("key%d", seqID)}, []byte{byte(seqID)}, nil
+	})
+}
+
+func TestKeyRotatorEverySegments(t *testing.T) {
+	p, err := hls.NewMediaPlaylist(3, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.SetKeyRotator(hls.NewKeyRotator(seqKeys(), 2, 0))
+	for i := 0; i < 5; i++ {
+		if _, err = p.Slide(&hls.MediaSegment{URI: fmt.Sprintf("seg%d.ts", i), Duration: 4}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// segments 2, 3, 4 are left: 2 and 3 use key2, 4 uses key4
+	out := p.String()
+	for _, exp := range []string{
+		"#EXT-X-MEDIA-SEQUENCE:2\n",
+		"#EXT-X-KEY:METHOD=AES-128,URI=\"key2\"\n#EXTINF:4.000,\nseg2.ts\n#EXTINF:4.000,\nseg3.ts\n#EXT-X-KEY:METHOD=AES-128,URI=\"key4\"\n#EXTINF:4.000,\nseg4.ts\n",
+	} {
+		if !strings.Contains(out, exp) {
+			t.Errorf("Expected %q in\n%s", exp, out)
+		}
+	}
+	if n := strings.Count(out, "#EXT-X-KEY"); n != 2 {
+		t.Errorf("Expected 2 EXT-X-KEY tags, got %d", n)
+	}
+}
+
+func TestKeyRotatorCarriesKey(t *testing.T) {
+	p, err := hls.NewMediaPlaylist(2, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.SetKeyRotator(hls.NewKeyRotator(seqKeys(), 3, 0))
+	for i := 0; i < 4; i++ {
+		if _, err = p.Slide(&hls.MediaSegment{URI: fmt.Sprintf("seg%d.ts", i), Duration: 4}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	exp := "#EXT-X-KEY:METHOD=AES-128,URI=\"key0\"\n#EXTINF:4.000,\nseg2.ts\n#EXT-X-KEY:METHOD=AES-128,URI=\"key3\"\n"
+	if out := p.String(); !strings.Contains(out, exp) {
+		t.Errorf("Expected %q in\n%s", exp, out)
+	}
+}
+
+func TestKeyRotatorPeriod(t *testing.T) {
+	p, err := hls.NewMediaPlaylist(10, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := hls.NewKeyRotator(seqKeys(), 0, 10)
+	p.SetKeyRotator(r)
+	for i := 0; i < 6; i++ {
+		if err = p.Append(&hls.MediaSegment{URI: "seg.ts", Duration: 4}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var rotated []int
+	for i, seg := range p.Segments[:6] {
+		if seg.Key != nil {
+			rotated = append(rotated, i)
+		}
+	}
+	if fmt.Sprint(rotated) != "[0 3]" {
+		t.Errorf("Expected rotations at [0 3], got %v", rotated)
+	}
+	if key, material := r.Key(); key.URI != "key3" || material[0] != 3 {
+		t.Errorf("Unexpected current key %+v %v", key, material)
+	}
+}
+
+func TestKeyRotatorError(t *testing.T) {
+	p, err := hls.NewMediaPlaylist(3, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.SetKeyRotator(hls.NewKeyRotator(hls.KeyProviderFunc(func(int) (*hls.Key, []byte, error) {
+		return nil, nil, fmt.Errorf("no keys")
+	}), 1, 0))
+	if err = p.Append(&hls.MediaSegment{URI: "seg.ts", Duration: 4}); err == nil {
+		t.Error("Expected provider error")
+	}
+	if p.Count() != 0 {
+		t.Errorf("Expected no segment appended, got %d", p.Count())
+	}
+}
+
+func TestRandomKeys(t *testing.T) {
+	key, material, err := hls.RandomKeys("https://keys.example.com/%d.key").NewKey(42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key.Method != "AES-128" || key.URI != "https://keys.example.com/42.key" || len(key.IV) != 34 || len(material) != 16 {
+		t.Errorf("Unexpected key %+v with %d bytes of material", key, len(material))
+	}
+}
+
+func TestKeyValidate(t *testing.T) {
+	valid := []*hls.Key{
+		{Method: hls.KeyMethodNone},
+		{Method: hls.KeyMethodAES128, URI: "key.bin"},
+		{Method: hls.KeyMethodAES128, URI: "key.bin", IV: "0x0123456789ABCDEF0123456789abcdef"},
+		{Method: hls.KeyMethodSampleAES, URI: "skd://key", Keyformat: hls.KeyformatFairPlay},
+		{Method: hls.KeyMethodSampleAESCTR, URI: "data:text/plain;base64,AAAA", Keyformat: hls.KeyformatWidevine},
+	}
+	for _, k := range valid {
+		if err := k.Validate(); err != nil {
+			t.Errorf("%+v: unexpected error %s", k, err)
+		}
+	}
+	invalid := []*hls.Key{
+		{Method: "AES128", URI: "key.bin"},
+		{Method: hls.KeyMethodAES128},
+		{Method: hls.KeyMethodNone, URI: "key.bin"},
+		{Method: hls.KeyMethodAES128, URI: "key.bin", IV: "0123456789ABCDEF0123456789abcdef"},
+		{Method: hls.KeyMethodAES128, URI: "key.bin", IV: "0x0123"},
+		{Method: hls.KeyMethodAES128, URI: "key.bin", IV: "0x0123456789ABCDEF0123456789abcdeg"},
+	}
+	for _, k := range invalid {
+		if err := k.Validate(); err == nil {
+			t.Errorf("%+v: expected error", k)
+		}
+	}
+}
+
+func TestMisspelledKeyMethod(t *testing.T) {
+	p, _ := hls.NewMediaPlaylist(3, 3)
+	if err := p.SetDefaultKey("AES128", "key.bin", "", "", ""); err == nil {
+		t.Error("SetDefaultKey: expected error for unknown method")
+	}
+	if err := p.SetDefaultKeys(&hls.Key{Method: hls.KeyMethodSampleAES}); err == nil {
+		t.Error("SetDefaultKeys: expected error for missing URI")
+	}
+	p.Append(hls.QuickSegment("test0.ts", "", 4))
+	if err := p.SetKey("AES128", "key.bin", "", "", ""); err == nil {
+		t.Error("SetKey: expected error for unknown method")
+	}
+	if p.Key != nil || p.Segments[0].Key != nil {
+		t.Error("Expected invalid keys not to be set")
+	}
+	p.SetKeyRotator(hls.NewKeyRotator(hls.KeyProviderFunc(func(seqID int) (*hls.Key, []byte, error) {
+		return &hls.Key{Method: "AES128", URI: "key.bin"}, nil, nil
+	}), 1, 0))
+	if err := p.Append(hls.QuickSegment("test1.ts", "", 4)); err == nil {
+		t.Error("KeyRotator: expected error for unknown method")
+	}
+	const playlist = "#EXTM3U\n#EXT-X-TARGETDURATION:10\n#EXT-X-KEY:METHOD=AES128,URI=\"key.bin\"\n#EXTINF:10.000,\ntest.ts\n"
+	if _, _, err := hls.DecodeFrom(strings.NewReader(playlist), true); err == nil {
+		t.Error("DecodeFrom: expected error for unknown method in strict mode")
+	}
+}
+
+func TestSampleAESVersion(t *testing.T) {
+	p, err := hls.NewMediaPlaylist(3, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = p.SetDefaultKeys(&hls.Key{Method: hls.KeyMethodSampleAES, URI: "key.bin"}); err != nil {
+		t.Fatal(err)
+	}
+	if p.Version() != 5 {
+		t.Errorf("Expected version 5 for SAMPLE-AES, got %d", p.Version())
+	}
+}
+
+func TestDecodeInvalidKey(t *testing.T) {
+	const playlist = `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-TARGETDURATION:10
+#EXT-X-KEY:METHOD=AES-128,URI="key.bin",IV=0x01
+#EXTINF:10.000,
+test.ts
+`
+	p, err := hls.NewMediaPlaylist(1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = p.DecodeFrom(strings.NewReader(playlist), true); err == nil {
+		t.Error("Expected error for invalid IV in strict mode")
+	}
+	p, _ = hls.NewMediaPlaylist(1, 1)
+	if err = p.DecodeFrom(strings.NewReader(playlist), false); err != nil {
+		t.Errorf("Unexpected error in non-strict mode: %s", err)
+	}
+	if err = p.Key.Validate(); err == nil {
+		t.Error("Expected error for invalid IV")
+	}
+}
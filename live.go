@@ -0,0 +1,255 @@
+package hls
+
+import (
+	"sync"
+	"time"
+)
+
+// LivePlaylist wraps a MediaPlaylist for concurrent use: one packager
+// goroutine appends or slides segments while any number of readers, such as
+// HTTP handlers, get encoded snapshots of the playlist.
+// The wrapped playlist must not be accessed directly once wrapped.
+type LivePlaylist struct {
+	mu       sync.RWMutex
+	p        *MediaPlaylist
+	snapshot []byte        // encoded playlist, nil when outdated
+	delta    []byte        // encoded delta update, nil when outdated
+	deltaV2  []byte        // encoded delta update skipping date ranges, nil when outdated
+	changes  chan struct{} // closed on the next change
+}
+
+// NewLivePlaylist creates a live playlist with a new sliding media playlist
+// of the window size and capacity.
+func NewLivePlaylist(winsize, capacity int) (*LivePlaylist, error) {
+	p, err := NewMediaPlaylist(winsize, capacity)
+	if err != nil {
+		return nil, err
+	}
+	return WrapLivePlaylist(p), nil
+}
+
+// WrapLivePlaylist wraps an existing media playlist.
+func WrapLivePlaylist(p *MediaPlaylist) *LivePlaylist {
+	return &LivePlaylist{p: p, changes: make(chan struct{})}
+}
+
+// Bytes returns the encoded playlist. The snapshot is shared between
+// callers until the next change of the playlist and must not be modified.
+func (l *LivePlaylist) Bytes() []byte {
+	l.mu.RLock()
+	b := l.snapshot
+	l.mu.RUnlock()
+	if b != nil {
+		return b
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.snapshot == nil {
+		l.snapshot = append([]byte(nil), l.p.Encode().Bytes()...)
+	}
+	return l.snapshot
+}
+
+// DeltaBytes returns the encoded Playlist Delta Update, see
+// MediaPlaylist.EncodeDelta. Like Bytes, the snapshot must not be modified.
+func (l *LivePlaylist) DeltaBytes(skipDateRanges bool) []byte {
+	cache := &l.delta
+	if skipDateRanges {
+		cache = &l.deltaV2
+	}
+	l.mu.RLock()
+	b := *cache
+	l.mu.RUnlock()
+	if b != nil {
+		return b
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if *cache == nil {
+		*cache = l.p.EncodeDelta(skipDateRanges).Bytes()
+	}
+	return *cache
+}
+
+// Changed returns a channel which is closed on the next change of the
+// playlist, for readers waiting for new segments.
+func (l *LivePlaylist) Changed() <-chan struct{} {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.changes
+}
+
+// Subscribe subscribes to the changes of the playlist, see MediaPlaylist.Subscribe.
+func (l *LivePlaylist) Subscribe(buffer int) (<-chan Event, func()) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.p.Subscribe(buffer)
+}
+
+// String returns the encoded playlist as a string.
+func (l *LivePlaylist) String() string {
+	return string(l.Bytes())
+}
+
+// Update calls fn with the wrapped playlist under the write lock, for changes
+// not covered by the other methods. The playlist cache is reset afterwards.
+func (l *LivePlaylist) Update(fn func(p *MediaPlaylist) error) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	err := fn(l.p)
+	l.changed()
+	return err
+}
+
+// View calls fn with the wrapped playlist under the read lock.
+// fn must not change the playlist, nor call Encode on it which updates
+// its cache; use Bytes to get the encoded playlist. Lookups by time with
+// SegmentAt and SegmentAtTime are safe.
+func (l *LivePlaylist) View(fn func(p *MediaPlaylist)) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	fn(l.p)
+}
+
+// changed drops the snapshots and the playlist cache and wakes up the
+// readers waiting for a change, l.mu must be held.
+func (l *LivePlaylist) changed() {
+	l.snapshot, l.delta, l.deltaV2 = nil, nil, nil
+	l.p.ResetCache()
+	close(l.changes)
+	l.changes = make(chan struct{})
+}
+
+// Append appends a segment to the playlist, see MediaPlaylist.Append.
+func (l *LivePlaylist) Append(seg *MediaSegment) error {
+	return l.Update(func(p *MediaPlaylist) error {
+		return p.Append(seg)
+	})
+}
+
+// Slide removes the oldest segment if the window is full and appends
+// a segment, see MediaPlaylist.Slide.
+func (l *LivePlaylist) Slide(seg *MediaSegment) (removed *MediaSegment, err error) {
+	err = l.Update(func(p *MediaPlaylist) error {
+		removed, err = p.Slide(seg)
+		return err
+	})
+	return
+}
+
+// Remove removes the oldest segment, see MediaPlaylist.Remove.
+func (l *LivePlaylist) Remove() (removed *MediaSegment, err error) {
+	err = l.Update(func(p *MediaPlaylist) error {
+		removed, err = p.Remove()
+		return err
+	})
+	return
+}
+
+// Close ends the playlist with EXT-X-ENDLIST.
+func (l *LivePlaylist) Close() {
+	l.Update(func(p *MediaPlaylist) error {
+		p.Close()
+		return nil
+	})
+}
+
+// Closed reports whether the playlist is closed.
+func (l *LivePlaylist) Closed() bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.p.Closed
+}
+
+// Count returns the number of segments in the playlist.
+func (l *LivePlaylist) Count() int {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.p.Count()
+}
+
+// SeqNo returns the media sequence number of the first segment.
+func (l *LivePlaylist) SeqNo() int {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.p.SeqNo
+}
+
+// SetKeys sets the encryption keys of the current segment, see MediaPlaylist.SetKeys.
+func (l *LivePlaylist) SetKeys(keys ...*Key) error {
+	return l.Update(func(p *MediaPlaylist) error {
+		return p.SetKeys(keys...)
+	})
+}
+
+// SetDefaultKeys sets the default encryption keys, see MediaPlaylist.SetDefaultKeys.
+func (l *LivePlaylist) SetDefaultKeys(keys ...*Key) error {
+	return l.Update(func(p *MediaPlaylist) error {
+		return p.SetDefaultKeys(keys...)
+	})
+}
+
+// SetKeyRotator attaches a key rotator, see MediaPlaylist.SetKeyRotator.
+func (l *LivePlaylist) SetKeyRotator(r *KeyRotator) {
+	l.Update(func(p *MediaPlaylist) error {
+		p.SetKeyRotator(r)
+		return nil
+	})
+}
+
+// SetMap sets the map of the current segment, see MediaPlaylist.SetMap.
+func (l *LivePlaylist) SetMap(uri string, limit, offset int) error {
+	return l.Update(func(p *MediaPlaylist) error {
+		return p.SetMap(uri, limit, offset)
+	})
+}
+
+// SetRange sets the byte range of the current segment, see MediaPlaylist.SetRange.
+func (l *LivePlaylist) SetRange(limit, offset int) error {
+	return l.Update(func(p *MediaPlaylist) error {
+		return p.SetRange(limit, offset)
+	})
+}
+
+// SetSCTE35 sets the SCTE-35 cue of the current segment.
+func (l *LivePlaylist) SetSCTE35(scte35 *SCTE) error {
+	return l.Update(func(p *MediaPlaylist) error {
+		return p.SetSCTE35(scte35)
+	})
+}
+
+// SetDateRange adds an EXT-X-DATERANGE tag to the current segment.
+func (l *LivePlaylist) SetDateRange(dr *DateRange) error {
+	return l.Update(func(p *MediaPlaylist) error {
+		return p.SetDateRange(dr)
+	})
+}
+
+// SetDiscontinuity sets the discontinuity flag of the current segment.
+func (l *LivePlaylist) SetDiscontinuity() error {
+	return l.Update(func(p *MediaPlaylist) error {
+		return p.SetDiscontinuity()
+	})
+}
+
+// SetProgramDateTime sets the program date and time of the current segment.
+func (l *LivePlaylist) SetProgramDateTime(value time.Time) error {
+	return l.Update(func(p *MediaPlaylist) error {
+		return p.SetProgramDateTime(value)
+	})
+}
+
+// StartAdBreak starts an ad break, see MediaPlaylist.StartAdBreak.
+func (l *LivePlaylist) StartAdBreak(syntax SCTE35Syntax, duration float64, cue string, dateRange bool) error {
+	return l.Update(func(p *MediaPlaylist) error {
+		return p.StartAdBreak(syntax, duration, cue, dateRange)
+	})
+}
+
+// EndAdBreak ends the ad break, see MediaPlaylist.EndAdBreak.
+func (l *LivePlaylist) EndAdBreak() {
+	l.Update(func(p *MediaPlaylist) error {
+		p.EndAdBreak()
+		return nil
+	})
+}
@@ -0,0 +1,82 @@
+package hls_test
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ShevaXu/hls"
+)
+
+func TestLivePlaylistSnapshots(t *testing.T) {
+	l, err := hls.NewLivePlaylist(3, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = l.Append(hls.QuickSegment("test0.ts", "", 4)); err != nil {
+		t.Fatal(err)
+	}
+	first := l.Bytes()
+	if &l.Bytes()[0] != &first[0] {
+		t.Error("Expected the snapshot to be shared until the next change")
+	}
+	saved := append([]byte(nil), first...)
+	if _, err = l.Slide(hls.QuickSegment("test1.ts", "", 4)); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(first, saved) {
+		t.Error("Snapshot changed after Slide")
+	}
+	if !strings.Contains(l.String(), "test1.ts") {
+		t.Errorf("Expected new segment in\n%s", l.String())
+	}
+	if err = l.SetDiscontinuity(); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(l.String(), "#EXT-X-DISCONTINUITY\n#EXTINF:4.000,\ntest1.ts") {
+		t.Errorf("Expected discontinuity in\n%s", l.String())
+	}
+	l.Close()
+	if !l.Closed() || !strings.HasSuffix(l.String(), "#EXT-X-ENDLIST\n") {
+		t.Errorf("Expected closed playlist\n%s", l.String())
+	}
+}
+
+func TestLivePlaylistParallel(t *testing.T) {
+	l, err := hls.NewLivePlaylist(5, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				b := l.Bytes()
+				if len(b) > 0 && !bytes.HasPrefix(b, []byte("#EXTM3U\n")) {
+					t.Error("Invalid snapshot")
+					return
+				}
+			}
+		}()
+	}
+	for i := 0; i < 1000; i++ {
+		if _, err = l.Slide(hls.QuickSegment(fmt.Sprintf("test%d.ts", i), "", 4)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(done)
+	wg.Wait()
+	if l.SeqNo() != 995 || l.Count() != 5 {
+		t.Errorf("Expected sequence 995 with 5 segments, got %d with %d", l.SeqNo(), l.Count())
+	}
+}
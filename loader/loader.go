@@ -0,0 +1,186 @@
+// Package loader loads a master playlist along with all its media
+// playlists, over HTTP or from a file system.
+package loader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/ShevaXu/hls"
+)
+
+// DefaultWorkers is the number of media playlists loaded concurrently
+// if Loader.Workers is zero.
+const DefaultWorkers = 4
+
+// Loader loads master playlists and their media playlists.
+type Loader struct {
+	Opener  Opener
+	Workers int  // maximum number of concurrent loads, DefaultWorkers if zero
+	Strict  bool // decode the playlists in strict mode
+}
+
+// NewHTTP creates a loader of playlists over HTTP.
+func NewHTTP(client *http.Client) *Loader {
+	return &Loader{Opener: &HTTPOpener{Client: client}}
+}
+
+// NewFS creates a loader of playlists in a file system.
+func NewFS(fsys fs.FS) *Loader {
+	return &Loader{Opener: &FSOpener{FS: fsys}}
+}
+
+// URIError records the failure to load a media playlist.
+type URIError struct {
+	URI string
+	Err error
+}
+
+func (e *URIError) Error() string {
+	return e.URI + ": " + e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *URIError) Unwrap() error {
+	return e.Err
+}
+
+// Result is a loaded master playlist.
+type Result struct {
+	URI    string
+	Master *hls.MasterPlaylist
+	// URIs are the resolved URIs of the variants and renditions,
+	// which keep the URIs found in the master playlist.
+	URIs map[string]string
+	// Renditions are the media playlists of the EXT-X-MEDIA renditions.
+	// The media playlists of the variants are in Variant.Chunklist.
+	Renditions map[*hls.Alternative]*hls.MediaPlaylist
+	// Errors are the media playlists which failed to load, sorted by URI.
+	Errors []*URIError
+}
+
+// Load loads the master playlist at uri and every media playlist it refers
+// to. It fails only if the master playlist can not be loaded; the failures
+// of media playlists are reported in Result.Errors. Media playlists shared
+// by several variants or renditions are loaded once.
+func (l *Loader) Load(ctx context.Context, uri string) (*Result, error) {
+	p, listType, err := l.decode(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	if listType != hls.ListTypeMaster {
+		return nil, fmt.Errorf("%s is not a master playlist", uri)
+	}
+	res := &Result{
+		URI:        uri,
+		Master:     p.(*hls.MasterPlaylist),
+		URIs:       make(map[string]string),
+		Renditions: make(map[*hls.Alternative]*hls.MediaPlaylist),
+	}
+
+	// resolve and deduplicate the URIs of the media playlists
+	var uris []string
+	for _, v := range res.Master.Variants {
+		refs := []string{v.URI}
+		for _, alt := range v.Alternatives {
+			refs = append(refs, alt.URI)
+		}
+		for _, ref := range refs {
+			if _, ok := res.URIs[ref]; ok || ref == "" {
+				continue
+			}
+			abs, err := l.Opener.Resolve(uri, ref)
+			if err != nil {
+				res.Errors = append(res.Errors, &URIError{ref, err})
+				res.URIs[ref] = ""
+				continue
+			}
+			res.URIs[ref] = abs
+			uris = append(uris, abs)
+		}
+	}
+	media := l.loadMedia(ctx, dedupe(uris), res)
+
+	for _, v := range res.Master.Variants {
+		if m, ok := media[res.URIs[v.URI]]; ok {
+			v.Chunklist = m
+		}
+		for _, alt := range v.Alternatives {
+			if m, ok := media[res.URIs[alt.URI]]; ok {
+				res.Renditions[alt] = m
+			}
+		}
+	}
+	sort.Slice(res.Errors, func(i, j int) bool { return res.Errors[i].URI < res.Errors[j].URI })
+	return res, ctx.Err()
+}
+
+// loadMedia loads the media playlists with a bounded pool of workers,
+// failures are added to res.Errors.
+func (l *Loader) loadMedia(ctx context.Context, uris []string, res *Result) map[string]*hls.MediaPlaylist {
+	workers := l.Workers
+	if workers <= 0 {
+		workers = DefaultWorkers
+	}
+	var (
+		mu    sync.Mutex
+		wg    sync.WaitGroup
+		media = make(map[string]*hls.MediaPlaylist)
+		jobs  = make(chan string)
+	)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for uri := range jobs {
+				p, listType, err := l.decode(ctx, uri)
+				if err == nil && listType != hls.ListTypeMedia {
+					err = errors.New("not a media playlist")
+				}
+				mu.Lock()
+				if err != nil {
+					res.Errors = append(res.Errors, &URIError{uri, err})
+				} else {
+					media[uri] = p.(*hls.MediaPlaylist)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, uri := range uris {
+		select {
+		case jobs <- uri:
+		case <-ctx.Done():
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	return media
+}
+
+// decode opens and decodes the playlist at uri.
+func (l *Loader) decode(ctx context.Context, uri string) (hls.Playlist, hls.ListType, error) {
+	r, err := l.Opener.Open(ctx, uri)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer r.Close()
+	return hls.DecodeFrom(r, l.Strict)
+}
+
+func dedupe(uris []string) []string {
+	seen := make(map[string]bool)
+	out := uris[:0]
+	for _, uri := range uris {
+		if !seen[uri] {
+			seen[uri] = true
+			out = append(out, uri)
+		}
+	}
+	return out
+}
@@ -0,0 +1,110 @@
+package loader_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+
+	"github.com/ShevaXu/hls/loader"
+)
+
+const media = `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-TARGETDURATION:4
+#EXTINF:4.000,
+seg0.ts
+#EXT-X-ENDLIST
+`
+
+var pkg = fstest.MapFS{
+	"vod/master.m3u8": {Data: []byte(`#EXTM3U
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="aud",NAME="en",DEFAULT=YES,URI="audio/en.m3u8"
+#EXT-X-STREAM-INF:BANDWIDTH=1000000,AUDIO="aud"
+hd/index.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=500000,AUDIO="aud"
+sd/index.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=250000,AUDIO="aud"
+missing/index.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=1000001,AUDIO="aud"
+hd/index.m3u8
+`)},
+	"vod/hd/index.m3u8":    {Data: []byte(media)},
+	"vod/sd/index.m3u8":    {Data: []byte(media)},
+	"vod/audio/en.m3u8":    {Data: []byte(media)},
+	"vod/master-only.m3u8": {Data: []byte(media)},
+}
+
+func checkResult(t *testing.T, res *loader.Result) {
+	t.Helper()
+	vs := res.Master.Variants
+	if len(vs) != 4 {
+		t.Fatalf("Expected 4 variants, got %d", len(vs))
+	}
+	if vs[0].Chunklist == nil || vs[1].Chunklist == nil || vs[2].Chunklist != nil {
+		t.Errorf("Unexpected chunklists %v %v %v", vs[0].Chunklist, vs[1].Chunklist, vs[2].Chunklist)
+	}
+	if vs[3].Chunklist != vs[0].Chunklist {
+		t.Error("Expected the same media playlist for the same URI")
+	}
+	if vs[0].Chunklist != nil && vs[0].Chunklist.Segments[0].URI != "seg0.ts" {
+		t.Errorf("Unexpected segment %s", vs[0].Chunklist.Segments[0].URI)
+	}
+	if len(res.Renditions) != 1 || res.Renditions[vs[0].Alternatives[0]] == nil {
+		t.Errorf("Unexpected renditions %v", res.Renditions)
+	}
+	if len(res.Errors) != 1 || res.Errors[0].URI != res.URIs["missing/index.m3u8"] {
+		t.Errorf("Unexpected errors %v", res.Errors)
+	}
+}
+
+func TestLoadFS(t *testing.T) {
+	l := loader.NewFS(pkg)
+	l.Workers = 2
+	res, err := l.Load(context.Background(), "vod/master.m3u8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkResult(t, res)
+	if res.URIs["audio/en.m3u8"] != "vod/audio/en.m3u8" {
+		t.Errorf("Unexpected resolved URI %s", res.URIs["audio/en.m3u8"])
+	}
+	if _, err = l.Load(context.Background(), "vod/master-only.m3u8"); err == nil {
+		t.Error("Expected error for a media playlist")
+	}
+	if _, err = l.Load(context.Background(), "vod/none.m3u8"); err == nil {
+		t.Error("Expected error for a missing master playlist")
+	}
+}
+
+func TestLoadHTTP(t *testing.T) {
+	srv := httptest.NewServer(http.FileServer(http.FS(pkg)))
+	defer srv.Close()
+	res, err := loader.NewHTTP(srv.Client()).Load(context.Background(), srv.URL+"/vod/master.m3u8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkResult(t, res)
+	if res.URIs["hd/index.m3u8"] != srv.URL+"/vod/hd/index.m3u8" {
+		t.Errorf("Unexpected resolved URI %s", res.URIs["hd/index.m3u8"])
+	}
+}
+
+func TestFSOpenerResolve(t *testing.T) {
+	o := &loader.FSOpener{FS: pkg}
+	for _, test := range [][3]string{
+		{"vod/master.m3u8", "hd/index.m3u8?x=1", "vod/hd/index.m3u8"},
+		{"vod/hd/index.m3u8", "../audio/en.m3u8", "vod/audio/en.m3u8"},
+		{"vod/hd/index.m3u8", "/keys/1.key", "keys/1.key"},
+	} {
+		if got, err := o.Resolve(test[0], test[1]); err != nil || got != test[2] {
+			t.Errorf("Resolve(%q, %q) = %q, %v, expected %q", test[0], test[1], got, err, test[2])
+		}
+	}
+	for _, ref := range []string{"https://example.com/a.m3u8", "../../a.m3u8"} {
+		if _, err := o.Resolve("vod/master.m3u8", ref); err == nil {
+			t.Errorf("Expected error for %s", ref)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package loader
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// Opener opens the resources of an HLS package by URI.
+type Opener interface {
+	// Open opens the resource at uri.
+	Open(ctx context.Context, uri string) (io.ReadCloser, error)
+	// Resolve resolves the reference ref found in the playlist at base.
+	Resolve(base, ref string) (string, error)
+}
+
+// HTTPOpener opens resources over HTTP.
+type HTTPOpener struct {
+	Client *http.Client // http.DefaultClient if nil
+}
+
+// Open sends a GET request for uri and returns the response body.
+func (o *HTTPOpener) Open(ctx context.Context, uri string) (io.ReadCloser, error) {
+	req, err := http.NewRequest(http.MethodGet, uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	client := o.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("GET %s: %s", uri, resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// Resolve resolves ref as a URL reference.
+func (o *HTTPOpener) Resolve(base, ref string) (string, error) {
+	b, err := url.Parse(base)
+	if err != nil {
+		return "", err
+	}
+	u, err := b.Parse(ref)
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+// FSOpener opens resources in a file system, URIs are slash separated
+// paths relative to its root as fs.FS expects.
+type FSOpener struct {
+	FS fs.FS
+}
+
+// Open opens the file at uri.
+func (o *FSOpener) Open(ctx context.Context, uri string) (io.ReadCloser, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return o.FS.Open(uri)
+}
+
+// Resolve joins ref to the directory of base, dropping any query.
+// Absolute URLs can not be opened in a file system.
+func (o *FSOpener) Resolve(base, ref string) (string, error) {
+	u, err := url.Parse(ref)
+	if err != nil {
+		return "", err
+	}
+	if u.IsAbs() || u.Host != "" {
+		return "", fmt.Errorf("%s is not a local path", ref)
+	}
+	p := u.Path
+	if !strings.HasPrefix(p, "/") {
+		p = path.Join(path.Dir(base), p)
+	}
+	p = strings.TrimPrefix(path.Clean(p), "/")
+	if !fs.ValidPath(p) {
+		return "", fmt.Errorf("%s is outside the file system", ref)
+	}
+	return p, nil
+}
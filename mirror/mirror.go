@@ -0,0 +1,352 @@
+// Package mirror downloads a VOD asset over HTTP to a directory: the master
+// playlist, its media playlists and every segment, key and initialization
+// section, with URIs rewritten to the local copies.
+//
+// Downloads are resumable: files are written with a .part suffix until
+// complete, an interrupted download continues with a range request, and
+// complete files are not downloaded again. A manifest of SHA-256 checksums
+// in the format of sha256sum is written along with the files.
+package mirror
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ShevaXu/hls"
+	"github.com/ShevaXu/hls/loader"
+)
+
+// ManifestName is the name of the checksum manifest.
+const ManifestName = "manifest.sha256"
+
+// partSuffix marks incomplete downloads.
+const partSuffix = ".part"
+
+// Mirror downloads HLS assets.
+type Mirror struct {
+	Client  *http.Client // http.DefaultClient if nil
+	Dir     string       // destination directory
+	Workers int          // maximum number of concurrent downloads, loader.DefaultWorkers if zero
+}
+
+// Manifest maps the paths of the mirrored files, relative to the directory,
+// to their SHA-256 checksums in hex.
+type Manifest map[string]string
+
+// resource is a file to download.
+type resource struct {
+	url   string
+	path  string // local path relative to the directory
+	end   int64  // end of the last byte range, zero for the whole file
+	whole bool   // referred to without byte range
+}
+
+// Mirror downloads the master playlist at rawurl and everything it refers
+// to. The master playlist is written under its base name at the root of
+// the directory and the other files at their path relative to it; files
+// from other hosts or directories go under ext/<host>/<path>, with the
+// colon before a port replaced by an underscore. Files whose local path
+// would escape its directory, e.g. with encoded dot segments, are not
+// downloaded and keep their absolute URI.
+// Files referred to by byte ranges only are downloaded up to the end of
+// the last range.
+func (m *Mirror) Mirror(ctx context.Context, rawurl string) (Manifest, error) {
+	root, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	res, err := (&loader.Loader{Opener: &loader.HTTPOpener{Client: m.Client}, Workers: m.Workers}).Load(ctx, rawurl)
+	if err != nil {
+		return nil, err
+	}
+	if len(res.Errors) > 0 {
+		return nil, res.Errors[0]
+	}
+	// local returns the local path of the URL, false if it is not safe
+	local := func(u *url.URL) (string, bool) {
+		dir := path.Dir(root.Path) + "/"
+		if u.Scheme == root.Scheme && u.Host == root.Host && strings.HasPrefix(u.Path, dir) {
+			rel := path.Clean(strings.TrimPrefix(u.Path, dir))
+			return rel, rel != "." && fs.ValidPath(rel)
+		}
+		host := strings.Replace(u.Host, ":", "_", -1)
+		rel := path.Clean(strings.TrimPrefix(u.Path, "/"))
+		return path.Join("ext", host, rel), fs.ValidPath(host) && rel != "." && fs.ValidPath(rel)
+	}
+
+	// collect the media playlists and the files they refer to
+	media := make(map[string]*hls.MediaPlaylist) // by absolute URL
+	for _, v := range res.Master.Variants {
+		if v.Chunklist != nil {
+			media[res.URIs[v.URI]] = v.Chunklist
+		}
+		for _, alt := range v.Alternatives {
+			if p := res.Renditions[alt]; p != nil {
+				media[res.URIs[alt.URI]] = p
+			}
+		}
+	}
+	resources := make(map[string]*resource) // by local path
+	playlists := make(map[string][]byte)    // encoded playlists by local path
+	for mediaURL, p := range media {
+		base, _ := url.Parse(mediaURL)
+		add := func(uri string, limit, offset int) {
+			u, err := base.Parse(uri)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+				return // skd: and data: keys
+			}
+			name, ok := local(u)
+			if !ok {
+				return
+			}
+			r := resources[name]
+			if r == nil {
+				r = &resource{url: u.String(), path: name}
+				resources[name] = r
+			}
+			if limit > 0 {
+				if end := int64(offset + limit); end > r.end {
+					r.end = end
+				}
+			} else {
+				r.whole = true
+			}
+		}
+		for _, key := range append([]*hls.Key{p.Key}, p.Keys...) {
+			if key != nil && key.Method != hls.KeyMethodNone {
+				add(key.URI, 0, 0)
+			}
+		}
+		if p.Map != nil {
+			add(p.Map.URI, p.Map.Limit, p.Map.Offset)
+		}
+		for _, seg := range p.Segments[:p.Count()] {
+			if seg == nil {
+				continue
+			}
+			for _, key := range append([]*hls.Key{seg.Key}, seg.Keys...) {
+				if key != nil && key.Method != hls.KeyMethodNone {
+					add(key.URI, 0, 0)
+				}
+			}
+			if seg.Map != nil {
+				add(seg.Map.URI, seg.Map.Limit, seg.Map.Offset)
+			}
+			add(seg.URI, seg.Limit, seg.Offset)
+		}
+		if name, ok := local(base); ok {
+			playlists[name] = p.EncodeWith(rewriter(base, local)).Bytes()
+		}
+	}
+	playlists[path.Base(root.Path)] = res.Master.EncodeWith(rewriter(root, local)).Bytes()
+
+	manifest := make(Manifest)
+	for name, data := range playlists {
+		if err = m.writeFile(name, data); err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256(data)
+		manifest[name] = hex.EncodeToString(sum[:])
+	}
+	if err = m.download(ctx, resources, manifest); err != nil {
+		return nil, err
+	}
+	return manifest, m.writeManifest(manifest)
+}
+
+// rewriter rewrites the URIs of the playlist at base to local paths relative
+// to it, or to absolute URIs if they have no local path.
+func rewriter(base *url.URL, local func(*url.URL) (string, bool)) hls.URIRewriter {
+	name, _ := local(base)
+	from := path.Dir(name)
+	return hls.URIRewriterFunc(func(_ hls.URIKind, uri string) string {
+		u, err := base.Parse(uri)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return uri
+		}
+		name, ok := local(u)
+		if !ok {
+			return u.String()
+		}
+		rel, err := filepath.Rel(filepath.FromSlash(from), filepath.FromSlash(name))
+		if err != nil {
+			return u.String()
+		}
+		return filepath.ToSlash(rel)
+	})
+}
+
+// download downloads the resources with a bounded pool of workers and adds
+// their checksums to the manifest.
+func (m *Mirror) download(ctx context.Context, resources map[string]*resource, manifest Manifest) error {
+	workers := m.Workers
+	if workers <= 0 {
+		workers = loader.DefaultWorkers
+	}
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		firstErr error
+		jobs     = make(chan *resource)
+	)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for r := range jobs {
+				sum, err := m.fetch(ctx, r)
+				mu.Lock()
+				if err == nil {
+					manifest[r.path] = sum
+				} else if firstErr == nil {
+					firstErr = fmt.Errorf("%s: %s", r.url, err)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	paths := make([]string, 0, len(resources))
+	for p := range resources {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	for _, p := range paths {
+		select {
+		case jobs <- resources[p]:
+		case <-ctx.Done():
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
+
+// fetch downloads the resource unless it is complete already, resuming
+// a partial download, and returns its checksum.
+func (m *Mirror) fetch(ctx context.Context, r *resource) (string, error) {
+	file := filepath.Join(m.Dir, filepath.FromSlash(r.path))
+	if _, err := os.Stat(file); err == nil {
+		return checksum(file)
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return "", err
+	}
+	part := file + partSuffix
+	f, err := os.OpenFile(part, os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	start, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return "", err
+	}
+	end := int64(0) // exclusive end of the bytes to download, zero for the whole file
+	if !r.whole {
+		end = r.end
+	}
+	if end == 0 || start < end {
+		req, err := http.NewRequest(http.MethodGet, r.url, nil)
+		if err != nil {
+			return "", err
+		}
+		switch {
+		case end > 0:
+			req.Header.Set("Range", "bytes="+strconv.FormatInt(start, 10)+"-"+strconv.FormatInt(end-1, 10))
+		case start > 0:
+			req.Header.Set("Range", "bytes="+strconv.FormatInt(start, 10)+"-")
+		}
+		client := m.Client
+		if client == nil {
+			client = http.DefaultClient
+		}
+		resp, err := client.Do(req.WithContext(ctx))
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		switch resp.StatusCode {
+		case http.StatusPartialContent:
+		case http.StatusOK: // ranges not supported, start over
+			if err = f.Truncate(0); err != nil {
+				return "", err
+			}
+			if _, err = f.Seek(0, io.SeekStart); err != nil {
+				return "", err
+			}
+		case http.StatusRequestedRangeNotSatisfiable:
+			if start == 0 {
+				return "", fmt.Errorf("unexpected status %s", resp.Status)
+			}
+			// the partial file is complete already
+		default:
+			return "", fmt.Errorf("unexpected status %s", resp.Status)
+		}
+		if resp.StatusCode != http.StatusRequestedRangeNotSatisfiable {
+			if _, err = io.Copy(f, resp.Body); err != nil {
+				return "", err
+			}
+		}
+	}
+	if err = f.Close(); err != nil {
+		return "", err
+	}
+	if err = os.Rename(part, file); err != nil {
+		return "", err
+	}
+	return checksum(file)
+}
+
+func (m *Mirror) writeFile(name string, data []byte) error {
+	file := filepath.Join(m.Dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(file, data, 0644)
+}
+
+// writeManifest writes the manifest sorted by path.
+func (m *Mirror) writeManifest(manifest Manifest) error {
+	names := make([]string, 0, len(manifest))
+	for name := range manifest {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	for _, name := range names {
+		b.WriteString(manifest[name])
+		b.WriteString("  ")
+		b.WriteString(name)
+		b.WriteRune('\n')
+	}
+	return m.writeFile(ManifestName, []byte(b.String()))
+}
+
+func checksum(file string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err = io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
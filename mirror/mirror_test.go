@@ -0,0 +1,236 @@
+package mirror_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+
+	"github.com/ShevaXu/hls"
+	"github.com/ShevaXu/hls/mirror"
+)
+
+func content(n int) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte(i)
+	}
+	return b
+}
+
+var asset = fstest.MapFS{
+	"vod/master.m3u8": {Data: []byte(`#EXTM3U
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="aud",NAME="en",DEFAULT=YES,URI="audio/en.m3u8"
+#EXT-X-STREAM-INF:BANDWIDTH=1000000,AUDIO="aud"
+hd/index.m3u8
+`)},
+	"vod/hd/index.m3u8": {Data: []byte(`#EXTM3U
+#EXT-X-VERSION:5
+#EXT-X-TARGETDURATION:4
+#EXT-X-MAP:URI="init.mp4"
+#EXT-X-KEY:METHOD=AES-128,URI="/keys/k.key"
+#EXT-X-BYTERANGE:1000@0
+#EXTINF:4.000,
+media.mp4
+#EXT-X-BYTERANGE:500
+#EXTINF:4.000,
+media.mp4
+#EXT-X-ENDLIST
+`)},
+	"vod/audio/en.m3u8": {Data: []byte(`#EXTM3U
+#EXT-X-TARGETDURATION:4
+#EXTINF:4.000,
+en0.aac?token=1
+#EXT-X-ENDLIST
+`)},
+	"vod/hd/init.mp4":   {Data: content(10)},
+	"vod/hd/media.mp4":  {Data: content(2000)},
+	"vod/audio/en0.aac": {Data: content(300)},
+	"keys/k.key":        {Data: content(16)},
+}
+
+type recorder struct {
+	mu     sync.Mutex
+	ranges map[string]string
+	next   http.Handler
+}
+
+func (r *recorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	r.ranges[req.URL.Path] = req.Header.Get("Range")
+	r.mu.Unlock()
+	r.next.ServeHTTP(w, req)
+}
+
+func TestMirror(t *testing.T) {
+	rec := &recorder{ranges: make(map[string]string), next: http.FileServer(http.FS(asset))}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+	dir := t.TempDir()
+
+	// an interrupted download of the media file
+	if err := os.MkdirAll(filepath.Join(dir, "hd"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "hd", "media.mp4.part"), content(2000)[:700], 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	m := &mirror.Mirror{Client: srv.Client(), Dir: dir, Workers: 2}
+	manifest, err := m.Mirror(context.Background(), srv.URL+"/vod/master.m3u8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := rec.ranges["/vod/hd/media.mp4"]; r != "bytes=700-1499" {
+		t.Errorf("Expected resumed range request, got %q", r)
+	}
+	media, err := os.ReadFile(filepath.Join(dir, "hd", "media.mp4"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(media, content(2000)[:1500]) {
+		t.Errorf("Unexpected media file of %d bytes", len(media))
+	}
+	for name, data := range map[string][]byte{
+		"hd/init.mp4":   content(10),
+		"audio/en0.aac": content(300),
+		"ext/" + strings.Replace(strings.TrimPrefix(srv.URL, "http://"), ":", "_", 1) + "/keys/k.key": content(16),
+	} {
+		got, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil || !bytes.Equal(got, data) {
+			t.Errorf("%s: unexpected content, %v", name, err)
+		}
+		sum := sha256.Sum256(data)
+		if manifest[name] != hex.EncodeToString(sum[:]) {
+			t.Errorf("%s: unexpected checksum %s", name, manifest[name])
+		}
+	}
+	playlist, err := os.ReadFile(filepath.Join(dir, "hd", "index.m3u8"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(playlist), `URI="../ext/`) || !strings.Contains(string(playlist), "\nmedia.mp4\n") {
+		t.Errorf("Unexpected playlist\n%s", playlist)
+	}
+	audio, _ := os.ReadFile(filepath.Join(dir, "audio", "en.m3u8"))
+	if !strings.Contains(string(audio), "\nen0.aac\n") {
+		t.Errorf("Unexpected playlist\n%s", audio)
+	}
+	sums, err := os.ReadFile(filepath.Join(dir, mirror.ManifestName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(sums), "\n"); n != len(manifest) || n != 7 {
+		t.Errorf("Expected 7 lines in manifest, got %d\n%s", n, sums)
+	}
+
+	// mirroring again downloads nothing
+	rec.ranges = make(map[string]string)
+	if _, err = m.Mirror(context.Background(), srv.URL+"/vod/master.m3u8"); err != nil {
+		t.Fatal(err)
+	}
+	for p := range rec.ranges {
+		if !strings.HasSuffix(p, ".m3u8") {
+			t.Errorf("Unexpected download of %s", p)
+		}
+	}
+}
+
+func TestMirrorImplicitRanges(t *testing.T) {
+	srv := httptest.NewServer(http.FileServer(http.FS(fstest.MapFS{
+		"vod/master.m3u8": {Data: []byte("#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=1000000\nindex.m3u8\n")},
+		"vod/index.m3u8": {Data: []byte(`#EXTM3U
+#EXT-X-VERSION:4
+#EXT-X-TARGETDURATION:4
+#EXT-X-BYTERANGE:100@0
+#EXTINF:4.000,
+media.ts
+#EXT-X-BYTERANGE:100
+#EXTINF:4.000,
+media.ts
+#EXT-X-BYTERANGE:150
+#EXTINF:4.000,
+media.ts
+#EXT-X-ENDLIST
+`)},
+		"vod/media.ts": {Data: content(400)},
+	})))
+	defer srv.Close()
+	dir := t.TempDir()
+
+	m := &mirror.Mirror{Client: srv.Client(), Dir: dir}
+	if _, err := m.Mirror(context.Background(), srv.URL+"/vod/master.m3u8"); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "index.m3u8"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, _, err := hls.DecodeFrom(bytes.NewReader(data), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	media, err := os.ReadFile(filepath.Join(dir, "media.ts"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the ranges of the mirrored playlist point at the same bytes as the original ones
+	for i, seg := range p.(*hls.MediaPlaylist).Segments[:3] {
+		offset := []int{0, 100, 200}[i]
+		if seg.Offset != offset || seg.Offset+seg.Limit > len(media) ||
+			!bytes.Equal(media[seg.Offset:seg.Offset+seg.Limit], content(400)[offset:offset+seg.Limit]) {
+			t.Errorf("Segment %d: unexpected range %d@%d\n%s", i, seg.Limit, seg.Offset, data)
+		}
+	}
+}
+
+func TestMirrorPathTraversal(t *testing.T) {
+	srv := httptest.NewServer(http.FileServer(http.FS(fstest.MapFS{
+		"vod/master.m3u8": {Data: []byte("#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=1000000\nindex.m3u8\n")},
+		"vod/index.m3u8": {Data: []byte(`#EXTM3U
+#EXT-X-TARGETDURATION:4
+#EXTINF:4.000,
+seg0.ts
+#EXTINF:4.000,
+%2e%2e/%2e%2e/tmp/evil.ts
+#EXTINF:4.000,
+http://127.0.0.1:1/%2e%2e/%2e%2e/tmp/y.ts
+#EXT-X-ENDLIST
+`)},
+		"vod/seg0.ts": {Data: content(100)},
+	})))
+	defer srv.Close()
+	root := t.TempDir()
+	dir := filepath.Join(root, "a", "b")
+
+	m := &mirror.Mirror{Client: srv.Client(), Dir: dir}
+	manifest, err := m.Mirror(context.Background(), srv.URL+"/vod/master.m3u8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(manifest) != 3 {
+		t.Errorf("Expected the playlists and seg0.ts only, got %v", manifest)
+	}
+	for _, name := range []string{"tmp", "a/tmp", "b/tmp", "a/b/ext"} {
+		if _, err = os.Stat(filepath.Join(root, filepath.FromSlash(name))); !os.IsNotExist(err) {
+			t.Errorf("Unexpected %s: %v", name, err)
+		}
+	}
+	playlist, err := os.ReadFile(filepath.Join(dir, "index.m3u8"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, uri := range []string{"\nseg0.ts\n", "\n" + srv.URL + "/vod/%2e%2e/%2e%2e/tmp/evil.ts\n", "\nhttp://127.0.0.1:1/%2e%2e/%2e%2e/tmp/y.ts\n"} {
+		if !strings.Contains(string(playlist), uri) {
+			t.Errorf("Expected %q in\n%s", uri, playlist)
+		}
+	}
+}
@@ -194,6 +194,23 @@ func decodeParamsLine(line string) map[string]string {
 	return out
 }
 
+// rangeOffset returns the offset of the byte range of the last segment,
+// which follows the range of the previous segment if offset is negative
+// (not present), see section 4.3.2.2 of RFC 8216.
+func rangeOffset(p *MediaPlaylist, offset int) int {
+	if offset >= 0 {
+		return offset
+	}
+	if p.count < 2 {
+		return 0
+	}
+	seg, prev := p.Segments[p.last()], p.Segments[(p.last()+p.capacity-1)%p.capacity]
+	if seg == nil || prev == nil || prev.Limit == 0 || prev.URI != seg.URI {
+		return 0
+	}
+	return prev.Offset + prev.Limit
+}
+
 // Parse one line of master playlist.
 func decodeLineOfMasterPlaylist(p *MasterPlaylist, state *decodingState, line string, strict bool) error {
 	var err error
@@ -375,7 +392,7 @@ func decodeLineOfMediaPlaylist(p *MediaPlaylist, wv *Widevine, state *decodingSt
 			state.tagInf = false
 		}
 		if state.tagRange {
-			if err = p.SetRange(state.limit, state.offset); strict && err != nil {
+			if err = p.SetRange(state.limit, rangeOffset(p, state.offset)); strict && err != nil {
 				return err
 			}
 			state.tagRange = false
@@ -386,6 +403,12 @@ func decodeLineOfMediaPlaylist(p *MediaPlaylist, wv *Widevine, state *decodingSt
 				return err
 			}
 		}
+		for _, dr := range state.dateRanges {
+			if err = p.SetDateRange(dr); strict && err != nil {
+				return err
+			}
+		}
+		state.dateRanges = nil
 		if state.tagDiscontinuity {
 			state.tagDiscontinuity = false
 			if err = p.SetDiscontinuity(); strict && err != nil {
@@ -398,13 +421,26 @@ func decodeLineOfMediaPlaylist(p *MediaPlaylist, wv *Widevine, state *decodingSt
 				return err
 			}
 		}
-		// If EXT-X-KEY appeared before reference to segment (EXTINF) then it linked to this segment
+		// If EXT-X-KEY appeared before reference to segment (EXTINF) then it linked to this segment,
+		// consecutive EXT-X-KEY tags (e.g. one per KEYFORMAT) make a set of keys
 		if state.tagKey {
-			p.Segments[p.last()].Key = &Key{state.xkey.Method, state.xkey.URI, state.xkey.IV, state.xkey.Keyformat, state.xkey.Keyformatversions}
+			seg := p.Segments[p.last()]
+			seg.Keys = make([]*Key, len(state.xkeys))
+			for i, k := range state.xkeys {
+				key := *k
+				seg.Keys[i] = &key
+			}
+			seg.Key = seg.Keys[0]
+			if len(seg.Keys) == 1 {
+				seg.Keys = nil
+			}
 			// First EXT-X-KEY may appeared in the header of the playlist and linked to first segment
 			// but for convenient playlist generation it also linked as default playlist key
 			if p.Key == nil {
-				p.Key = state.xkey
+				p.Key = state.xkeys[0]
+				if len(state.xkeys) > 1 {
+					p.Keys = state.xkeys
+				}
 			}
 			state.tagKey = false
 		}
@@ -439,6 +475,11 @@ func decodeLineOfMediaPlaylist(p *MediaPlaylist, wv *Widevine, state *decodingSt
 		if _, err = fmt.Sscanf(line, "#EXT-X-MEDIA-SEQUENCE:%d", &p.SeqNo); strict && err != nil {
 			return err
 		}
+	case strings.HasPrefix(line, "#EXT-X-DISCONTINUITY-SEQUENCE:"):
+		state.listType = ListTypeMedia
+		if _, err = fmt.Sscanf(line, "#EXT-X-DISCONTINUITY-SEQUENCE:%d", &p.DiscontinuitySeq); strict && err != nil {
+			return err
+		}
 	case strings.HasPrefix(line, "#EXT-X-PLAYLIST-TYPE:"):
 		state.listType = ListTypeMedia
 		var playlistType string
@@ -457,21 +498,28 @@ func decodeLineOfMediaPlaylist(p *MediaPlaylist, wv *Widevine, state *decodingSt
 		}
 	case strings.HasPrefix(line, "#EXT-X-KEY:"):
 		state.listType = ListTypeMedia
-		state.xkey = new(Key)
+		xkey := new(Key)
 		for k, v := range decodeParamsLine(line[11:]) {
 			switch k {
 			case "METHOD":
-				state.xkey.Method = v
+				xkey.Method = v
 			case "URI":
-				state.xkey.URI = v
+				xkey.URI = v
 			case "IV":
-				state.xkey.IV = v
+				xkey.IV = v
 			case "KEYFORMAT":
-				state.xkey.Keyformat = v
+				xkey.Keyformat = v
 			case "KEYFORMATVERSIONS":
-				state.xkey.Keyformatversions = v
+				xkey.Keyformatversions = v
 			}
 		}
+		if err = xkey.Validate(); strict && err != nil {
+			return err
+		}
+		if !state.tagKey {
+			state.xkeys = nil
+		}
+		state.xkeys = append(state.xkeys, xkey)
 		state.tagKey = true
 	case strings.HasPrefix(line, "#EXT-X-MAP:"):
 		state.listType = ListTypeMedia
@@ -487,6 +535,41 @@ func decodeLineOfMediaPlaylist(p *MediaPlaylist, wv *Widevine, state *decodingSt
 			}
 		}
 		state.tagMap = true
+	case strings.HasPrefix(line, "#EXT-X-SERVER-CONTROL:"):
+		state.listType = ListTypeMedia
+		p.ServerControl = new(ServerControl)
+		for k, v := range decodeParamsLine(line[22:]) {
+			switch k {
+			case "CAN-SKIP-UNTIL":
+				if p.ServerControl.CanSkipUntil, err = strconv.ParseFloat(v, 64); strict && err != nil {
+					return err
+				}
+			case "CAN-SKIP-DATERANGES":
+				p.ServerControl.CanSkipDateRanges = v == "YES"
+			case "HOLD-BACK":
+				if p.ServerControl.HoldBack, err = strconv.ParseFloat(v, 64); strict && err != nil {
+					return err
+				}
+			case "PART-HOLD-BACK":
+				if p.ServerControl.PartHoldBack, err = strconv.ParseFloat(v, 64); strict && err != nil {
+					return err
+				}
+			case "CAN-BLOCK-RELOAD":
+				p.ServerControl.CanBlockReload = v == "YES"
+			}
+		}
+	case strings.HasPrefix(line, "#EXT-X-START:"):
+		p.Start = new(StartPoint)
+		for k, v := range decodeParamsLine(line[13:]) {
+			switch k {
+			case "TIME-OFFSET":
+				if p.Start.TimeOffset, err = strconv.ParseFloat(v, 64); strict && err != nil {
+					return err
+				}
+			case "PRECISE":
+				p.Start.Precise = v == "YES"
+			}
+		}
 	case !state.tagProgramDateTime && strings.HasPrefix(line, "#EXT-X-PROGRAM-DATE-TIME:"):
 		state.tagProgramDateTime = true
 		state.listType = ListTypeMedia
@@ -496,7 +579,7 @@ func decodeLineOfMediaPlaylist(p *MediaPlaylist, wv *Widevine, state *decodingSt
 	case !state.tagRange && strings.HasPrefix(line, "#EXT-X-BYTERANGE:"):
 		state.tagRange = true
 		state.listType = ListTypeMedia
-		state.offset = 0
+		state.offset = -1 // not present
 		params := strings.SplitN(line[17:], "@", 2)
 		if state.limit, err = strconv.Atoi(params[0]); strict && err != nil {
 			return fmt.Errorf("Byterange sub-range length value parsing error: %s", err)
@@ -531,11 +614,41 @@ func decodeLineOfMediaPlaylist(p *MediaPlaylist, wv *Widevine, state *decodingSt
 		// EXT-OATCLS-SCTE35 contains the SCTE35 tag, EXT-X-CUE-OUT contains duration
 		state.scte.Time, _ = strconv.ParseFloat(line[15:], 64)
 		state.scte.CueType = SCTE35CueStart
+		state.cueOutSyntax = SyntaxOATCLS
+	case !state.tagSCTE35 && (line == "#EXT-X-CUE-OUT" || strings.HasPrefix(line, "#EXT-X-CUE-OUT:")):
+		// EXT-X-CUE-OUT without EXT-OATCLS-SCTE35, as DURATION=<d> or plain <d>
+		state.tagSCTE35 = true
+		state.listType = ListTypeMedia
+		state.scte = new(SCTE)
+		state.scte.Syntax = SyntaxElemental
+		state.scte.CueType = SCTE35CueStart
+		state.cueOutSyntax = SyntaxElemental
+		if len(line) > 15 {
+			value := line[15:]
+			if strings.Contains(value, "=") {
+				value = decodeParamsLine(value)["DURATION"]
+			}
+			state.scte.Time, _ = strconv.ParseFloat(value, 64)
+		}
+	case !state.tagSCTE35 && strings.HasPrefix(line, "#EXT-X-CUE-OUT-CONT:") && !strings.Contains(line, "="):
+		// Elemental EXT-X-CUE-OUT-CONT:<elapsed>/<duration>
+		state.tagSCTE35 = true
+		state.listType = ListTypeMedia
+		state.scte = new(SCTE)
+		state.scte.Syntax = SyntaxElemental
+		state.scte.CueType = SCTE35CueMid
+		state.cueOutSyntax = SyntaxElemental
+		params := strings.SplitN(line[20:], "/", 2)
+		state.scte.Elapsed, _ = strconv.ParseFloat(params[0], 64)
+		if len(params) > 1 {
+			state.scte.Time, _ = strconv.ParseFloat(params[1], 64)
+		}
 	case !state.tagSCTE35 && strings.HasPrefix(line, "#EXT-X-CUE-OUT-CONT:"):
 		state.tagSCTE35 = true
 		state.scte = new(SCTE)
 		state.scte.Syntax = SyntaxOATCLS
 		state.scte.CueType = SCTE35CueMid
+		state.cueOutSyntax = SyntaxOATCLS
 		for attribute, value := range decodeParamsLine(line[20:]) {
 			switch attribute {
 			case "SCTE35":
@@ -547,10 +660,124 @@ func decodeLineOfMediaPlaylist(p *MediaPlaylist, wv *Widevine, state *decodingSt
 			}
 		}
 	case !state.tagSCTE35 && line == "#EXT-X-CUE-IN":
+		// EXT-X-CUE-IN is shared by OATCLS and Elemental, follow the cue out
 		state.tagSCTE35 = true
 		state.scte = new(SCTE)
 		state.scte.Syntax = SyntaxOATCLS
+		if state.cueOutSyntax == SyntaxElemental {
+			state.scte.Syntax = SyntaxElemental
+		}
 		state.scte.CueType = SCTE35CueEnd
+	case !state.tagSCTE35 && strings.HasPrefix(line, "#EXT-X-CUE:"):
+		state.tagSCTE35 = true
+		state.listType = ListTypeMedia
+		state.scte = new(SCTE)
+		state.scte.Syntax = SyntaxAdobe
+		state.scte.CueType = SCTE35CueMid
+		for attribute, value := range decodeParamsLine(line[11:]) {
+			switch attribute {
+			case "TYPE":
+				switch value {
+				case "SpliceOut":
+					state.scte.CueType = SCTE35CueStart
+				case "SpliceIn":
+					state.scte.CueType = SCTE35CueEnd
+				}
+			case "CUE":
+				state.scte.Cue = value
+			case "ID":
+				state.scte.ID = value
+			case "TIME":
+				state.scte.Position, _ = strconv.ParseFloat(value, 64)
+			case "DURATION":
+				state.scte.Time, _ = strconv.ParseFloat(value, 64)
+			case "ELAPSED":
+				state.scte.Elapsed, _ = strconv.ParseFloat(value, 64)
+			}
+		}
+	case !state.tagSCTE35 && strings.HasPrefix(line, "#EXT-X-SPLICEPOINT-SCTE35:"):
+		state.tagSCTE35 = true
+		state.listType = ListTypeMedia
+		state.scte = new(SCTE)
+		state.scte.Syntax = SyntaxAdobeSplicePoint
+		state.scte.Cue = line[26:]
+	case !state.tagSCTE35 && strings.HasPrefix(line, "#EXT-X-SCTE35:"):
+		state.tagSCTE35 = true
+		state.listType = ListTypeMedia
+		state.scte = new(SCTE)
+		state.scte.Syntax = SyntaxElementalSCTE35
+		for attribute, value := range decodeParamsLine(line[14:]) {
+			switch attribute {
+			case "CUE":
+				state.scte.Cue = value
+			case "ID":
+				state.scte.ID = value
+			case "DURATION":
+				state.scte.Time, _ = strconv.ParseFloat(value, 64)
+			case "ELAPSED":
+				state.scte.Elapsed, _ = strconv.ParseFloat(value, 64)
+			case "CUE-OUT":
+				if value == "CONT" {
+					state.scte.CueType = SCTE35CueMid
+				} else {
+					state.scte.CueType = SCTE35CueStart
+				}
+			case "CUE-IN":
+				state.scte.CueType = SCTE35CueEnd
+			}
+		}
+	case strings.HasPrefix(line, "#EXT-X-DATERANGE:"):
+		state.listType = ListTypeMedia
+		dr := new(DateRange)
+		for attribute, value := range decodeParamsLine(line[17:]) {
+			switch attribute {
+			case "ID":
+				dr.ID = value
+			case "CLASS":
+				dr.Class = value
+			case "START-DATE":
+				if dr.StartDate, err = TimeParse(value); strict && err != nil {
+					return err
+				}
+			case "END-DATE":
+				if dr.EndDate, err = TimeParse(value); strict && err != nil {
+					return err
+				}
+			case "DURATION":
+				if dr.Duration, err = strconv.ParseFloat(value, 64); strict && err != nil {
+					return err
+				}
+			case "PLANNED-DURATION":
+				if dr.PlannedDuration, err = strconv.ParseFloat(value, 64); strict && err != nil {
+					return err
+				}
+			case "SCTE35-CMD":
+				dr.SCTE35Cmd = value
+			case "SCTE35-OUT":
+				dr.SCTE35Out = value
+			case "SCTE35-IN":
+				dr.SCTE35In = value
+			case "END-ON-NEXT":
+				dr.EndOnNext = value == "YES"
+			default:
+				if strings.HasPrefix(attribute, "X-") {
+					if dr.X == nil {
+						dr.X = make(map[string]string)
+					}
+					dr.X[attribute] = value
+				}
+			}
+		}
+		// keep the type of the client attributes
+		for _, kv := range reKeyValue.FindAllStringSubmatch(line[17:], -1) {
+			if strings.HasPrefix(kv[1], "X-") {
+				if dr.XQuoted == nil {
+					dr.XQuoted = make(map[string]bool)
+				}
+				dr.XQuoted[kv[1]] = strings.HasPrefix(kv[2], `"`)
+			}
+		}
+		state.dateRanges = append(state.dateRanges, dr)
 	case !state.tagDiscontinuity && strings.HasPrefix(line, "#EXT-X-DISCONTINUITY"):
 		state.tagDiscontinuity = true
 		state.listType = ListTypeMedia
@@ -6,7 +6,9 @@ import (
 	"fmt"
 	"os"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/ShevaXu/hls"
 )
@@ -139,10 +141,10 @@ func TestDecodeMediaPlaylistByteRange(t *testing.T) {
 	expected := []*hls.MediaSegment{
 		{URI: "video.ts", Duration: 10, Limit: 75232},
 		{URI: "video.ts", Duration: 10, Limit: 82112, Offset: 752321},
-		{URI: "video.ts", Duration: 10, Limit: 69864},
+		{URI: "video.ts", Duration: 10, Limit: 69864, Offset: 752321 + 82112}, // follows the previous range
 	}
 	for i, seg := range p.Segments {
-		if *seg != *expected[i] {
+		if !reflect.DeepEqual(seg, expected[i]) {
 			t.Errorf("exp: %+v\ngot: %+v", expected[i], seg)
 		}
 	}
@@ -435,6 +437,132 @@ func TestMediaPlaylistWithOATCLSSCTE35Tag(t *testing.T) {
 	}
 }
 
+func TestMediaPlaylistWithOtherSCTE35Syntaxes(t *testing.T) {
+	const cue = "/DAlAAAAAAAAAP/wFAUAAAABf+/+ANgNkv4AFJlwAAEBAQAA5xULLA=="
+	testCases := []struct {
+		playlistLocation string
+		expect           map[int]*hls.SCTE
+	}{
+		{
+			"sample-playlists/media-playlist-with-elemental-scte35.m3u8",
+			map[int]*hls.SCTE{
+				0: {Syntax: hls.SyntaxElemental, CueType: hls.SCTE35CueStart, Time: 15},
+				1: {Syntax: hls.SyntaxElemental, CueType: hls.SCTE35CueMid, Time: 15, Elapsed: 8.844},
+				2: {Syntax: hls.SyntaxElemental, CueType: hls.SCTE35CueEnd},
+			},
+		},
+		{
+			"sample-playlists/media-playlist-with-adobe-scte35.m3u8",
+			map[int]*hls.SCTE{
+				0: {Syntax: hls.SyntaxAdobe, CueType: hls.SCTE35CueStart, Cue: cue, ID: "1", Time: 15, Position: 414.171},
+				2: {Syntax: hls.SyntaxAdobe, CueType: hls.SCTE35CueEnd, ID: "1"},
+				3: {Syntax: hls.SyntaxAdobeSplicePoint, CueType: hls.SCTE35CueStart, Cue: cue},
+			},
+		},
+		{
+			"sample-playlists/media-playlist-with-elemental-scte35-tag.m3u8",
+			map[int]*hls.SCTE{
+				0: {Syntax: hls.SyntaxElementalSCTE35, CueType: hls.SCTE35CueStart, Cue: cue, Time: 15},
+				1: {Syntax: hls.SyntaxElementalSCTE35, CueType: hls.SCTE35CueMid, Cue: cue, Time: 15, Elapsed: 8.844},
+				2: {Syntax: hls.SyntaxElementalSCTE35, CueType: hls.SCTE35CueEnd, Cue: cue},
+			},
+		},
+	}
+	for _, c := range testCases {
+		f, err := os.Open(c.playlistLocation)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p, _, err := hls.DecodeFrom(bufio.NewReader(f), true)
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		pp := p.(*hls.MediaPlaylist)
+		for i := 0; i < pp.Count(); i++ {
+			if !reflect.DeepEqual(pp.Segments[i].SCTE, c.expect[i]) {
+				t.Errorf("%s segment %v (uri: %v)\ngot: %#v\nexp: %#v",
+					c.playlistLocation, i, pp.Segments[i].URI, pp.Segments[i].SCTE, c.expect[i],
+				)
+			}
+		}
+	}
+}
+
+func TestDecodeMediaPlaylistWithDateRange(t *testing.T) {
+	playlist := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-TARGETDURATION:10
+#EXT-X-PROGRAM-DATE-TIME:2020-01-01T00:00:00Z
+#EXT-X-DATERANGE:ID="splice-1",CLASS="ad",START-DATE="2020-01-01T00:00:00Z",PLANNED-DURATION=15,X-AD-ID="abc",SCTE35-OUT=0xFC30
+#EXTINF:10,
+media0.ts
+#EXT-X-DATERANGE:ID="splice-1",START-DATE="2020-01-01T00:00:00Z",END-DATE="2020-01-01T00:00:15Z",DURATION=15,SCTE35-IN=0xFC31
+#EXT-X-DATERANGE:ID="next",START-DATE="2020-01-01T00:00:10Z",END-ON-NEXT=YES
+#EXTINF:10,
+media1.ts
+`
+	p, _, err := hls.DecodeFrom(strings.NewReader(playlist), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pp := p.(*hls.MediaPlaylist)
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	expect := [][]*hls.DateRange{
+		{{ID: "splice-1", Class: "ad", StartDate: start, PlannedDuration: 15, SCTE35Out: "0xFC30", X: map[string]string{"X-AD-ID": "abc"}, XQuoted: map[string]bool{"X-AD-ID": true}}},
+		{
+			{ID: "splice-1", StartDate: start, EndDate: start.Add(15 * time.Second), Duration: 15, SCTE35In: "0xFC31"},
+			{ID: "next", StartDate: start.Add(10 * time.Second), EndOnNext: true},
+		},
+	}
+	for i, exp := range expect {
+		got := pp.Segments[i].DateRanges
+		if len(got) != len(exp) {
+			t.Fatalf("Segment %d: expected %d date ranges, got %d", i, len(exp), len(got))
+		}
+		for j := range exp {
+			if !got[j].StartDate.Equal(exp[j].StartDate) || !got[j].EndDate.Equal(exp[j].EndDate) {
+				t.Errorf("Segment %d date range %d dates\ngot: %+v\nexp: %+v", i, j, got[j], exp[j])
+			}
+			got[j].StartDate, got[j].EndDate = exp[j].StartDate, exp[j].EndDate
+			if !reflect.DeepEqual(got[j], exp[j]) {
+				t.Errorf("Segment %d date range %d\ngot: %+v\nexp: %+v", i, j, got[j], exp[j])
+			}
+		}
+	}
+}
+
+func TestDecodeMediaPlaylistWithMultipleKeys(t *testing.T) {
+	f, err := os.Open("sample-playlists/media-playlist-with-multiple-keys.m3u8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	p, _, err := hls.DecodeFrom(bufio.NewReader(f), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pp := p.(*hls.MediaPlaylist)
+	if len(pp.Keys) != 2 || pp.Key != pp.Keys[0] || pp.Key.URI != "skd://key1" {
+		t.Errorf("Unexpected default keys: %+v %+v", pp.Key, pp.Keys)
+	}
+	if keys := pp.Segments[0].Keys; len(keys) != 2 || pp.Segments[0].Key != keys[0] {
+		t.Errorf("Expected 2 keys for segment 0, got %+v", keys)
+	}
+	if pp.Segments[1].Key != nil || pp.Segments[1].Keys != nil {
+		t.Errorf("Expected no keys for segment 1, got %+v", pp.Segments[1].Keys)
+	}
+	keys := pp.Segments[2].Keys
+	if len(keys) != 2 || keys[0].URI != "skd://key2" || keys[1].Keyformat != "urn:uuid:edef8ba9-79d6-4ace-a3c8-27dcd51d21ed" {
+		t.Errorf("Unexpected keys for segment 2: %+v", keys)
+	}
+	out := pp.String()
+	// the keys of segment 0 are the default ones, written once
+	if n := strings.Count(out, "#EXT-X-KEY:"); n != 4 {
+		t.Errorf("Expected 4 EXT-X-KEY tags, got %d in:\n%s", n, out)
+	}
+}
+
 /***************************
  *  Code parsing examples  *
  ***************************/
@@ -519,3 +647,28 @@ func BenchmarkDecodeMediaPlaylist(b *testing.B) {
 		}
 	}
 }
+
+func TestDecodeMediaPlaylistWithServerControl(t *testing.T) {
+	const playlist = `#EXTM3U
+#EXT-X-VERSION:9
+#EXT-X-MEDIA-SEQUENCE:10
+#EXT-X-TARGETDURATION:4
+#EXT-X-SERVER-CONTROL:CAN-SKIP-UNTIL=24,HOLD-BACK=12,CAN-BLOCK-RELOAD=YES
+#EXTINF:4.000,
+seg10.ts
+`
+	p, err := hls.NewMediaPlaylist(1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = p.DecodeFrom(strings.NewReader(playlist), true); err != nil {
+		t.Fatal(err)
+	}
+	exp := hls.ServerControl{CanSkipUntil: 24, HoldBack: 12, CanBlockReload: true}
+	if p.ServerControl == nil || *p.ServerControl != exp {
+		t.Fatalf("Expected %+v, got %+v", exp, p.ServerControl)
+	}
+	if p.String() != playlist {
+		t.Errorf("Round trip mismatch:\n%s", p.String())
+	}
+}
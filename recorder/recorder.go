@@ -0,0 +1,275 @@
+// Package recorder records a live stream to a VOD package: it follows the
+// live media playlist, downloads the new segments as they appear and
+// builds a media playlist of them which is closed when the stream ends.
+package recorder
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"github.com/ShevaXu/hls"
+	"github.com/ShevaXu/hls/client"
+)
+
+// PlaylistName is the name of the recorded media playlist.
+const PlaylistName = "index.m3u8"
+
+// Recorder records a live media playlist.
+type Recorder struct {
+	Client *client.Client
+	Dir    string // destination of the segments and of the playlist
+
+	// OnSegment, if set, is called with every recorded segment.
+	OnSegment func(seg *hls.MediaSegment)
+
+	mu       sync.Mutex // guards the playlist and ver, updated on reloads too
+	playlist *hls.MediaPlaylist
+	maps     map[string]string // local names of the initialization sections by URL
+	key      []*hls.Key        // keys in effect in the live playlist
+	mapURL   string            // initialization section in effect
+	next     int               // media sequence number of the next segment, -1 at start
+	ver      int               // highest version of the live playlist
+}
+
+// New creates a recorder of the live media playlist at rawurl to the directory.
+func New(rawurl string, httpClient *http.Client, dir string) (*Recorder, error) {
+	c, err := client.New(rawurl, httpClient)
+	if err != nil {
+		return nil, err
+	}
+	return &Recorder{Client: c, Dir: dir}, nil
+}
+
+// Record follows the live playlist until it ends or the context is
+// cancelled. The recorded playlist starts at the media sequence number of
+// the first recorded segment and the segments are saved as <their media
+// sequence number in it><extension>, so names stay unique when the live
+// sequence goes back; the initialization sections are saved as
+// init-<n><extension> and key URIs are kept. AES-128 keys without IV get
+// the one derived from the live media sequence number of each segment.
+// Discontinuities, keys, program date times, SCTE-35 cues and date
+// ranges of the segments are preserved, and a discontinuity is added where
+// segments were missed. When the live playlist ends the recorded playlist
+// is closed as VOD. The playlist is written to PlaylistName in both cases
+// and returned along with the error which stopped the recording, if any.
+func (r *Recorder) Record(ctx context.Context) (*hls.MediaPlaylist, error) {
+	p, err := hls.NewMediaPlaylist(0, 1024)
+	if err != nil {
+		return nil, err
+	}
+	r.playlist, r.maps, r.key, r.mapURL, r.next, r.ver = p, make(map[string]string), nil, "", -1, 0
+	if err = os.MkdirAll(r.Dir, 0755); err != nil {
+		return nil, err
+	}
+
+	c := *r.Client
+	onReload := c.OnReload
+	c.OnReload = func(live *hls.MediaPlaylist) {
+		r.mu.Lock()
+		if live.Version() > r.ver {
+			r.ver = live.Version()
+		}
+		if live.TargetDuration > p.TargetDuration {
+			p.TargetDuration = live.TargetDuration
+		}
+		r.mu.Unlock()
+		if onReload != nil {
+			onReload(live)
+		}
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	segs := make(chan *hls.MediaSegment)
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Follow(ctx, segs)
+		close(segs)
+	}()
+	for seg := range segs {
+		if err = r.record(ctx, seg); err != nil {
+			cancel()
+			for range segs {
+			}
+			<-done
+			return p, r.finish(err)
+		}
+	}
+	if err = <-done; err == nil {
+		p.MediaType = hls.MediaTypeVOD
+		p.Close()
+	}
+	return p, r.finish(err)
+}
+
+// record downloads a segment and appends it to the playlist.
+func (r *Recorder) record(ctx context.Context, live *hls.MediaSegment) error {
+	seg := *live
+	if r.next < 0 {
+		r.mu.Lock()
+		r.playlist.SeqNo = seg.SeqID
+		r.mu.Unlock()
+	} else if seg.SeqID > r.next {
+		seg.Discontinuity = true // segments were missed
+	}
+	r.next = seg.SeqID + 1
+
+	// keep the tags which change the keys or the map only, and the keys
+	// of every segment with an IV derived from its media sequence number
+	changed := false
+	if seg.Key != nil {
+		keys := seg.Keys
+		if len(keys) == 0 {
+			keys = []*hls.Key{seg.Key}
+		}
+		if changed = !sameKeys(keys, r.key); changed {
+			r.key = keys
+		}
+	}
+	seg.Key, seg.Keys = nil, nil
+	if keys, derived := explicitIVs(r.key, seg.SeqID); changed || derived {
+		seg.Key = keys[0]
+		if len(keys) > 1 {
+			seg.Keys = keys
+		}
+		if derived {
+			r.mu.Lock()
+			if r.ver < 2 {
+				r.ver = 2 // IV attribute
+			}
+			r.mu.Unlock()
+		}
+	}
+	if seg.Map != nil {
+		if seg.Map.URI == r.mapURL {
+			seg.Map = nil
+		} else {
+			r.mapURL = seg.Map.URI
+			name, ok := r.maps[seg.Map.URI]
+			if !ok {
+				name = "init-" + strconv.Itoa(len(r.maps)) + ext(seg.Map.URI)
+				if err := r.download(ctx, seg.Map.URI, seg.Map.Limit, seg.Map.Offset, name); err != nil {
+					return err
+				}
+				r.maps[seg.Map.URI] = name
+			}
+			seg.Map = &hls.Map{URI: name}
+		}
+	}
+
+	name := strconv.Itoa(r.playlist.SeqNo+r.playlist.Count()) + ext(seg.URI)
+	if err := r.download(ctx, seg.URI, seg.Limit, seg.Offset, name); err != nil {
+		return err
+	}
+	seg.URI, seg.Limit, seg.Offset = name, 0, 0
+	r.mu.Lock()
+	err := r.playlist.AppendWithAutoExtend(&seg)
+	r.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if r.OnSegment != nil {
+		r.OnSegment(&seg)
+	}
+	return nil
+}
+
+// finish writes the playlist and returns err.
+func (r *Recorder) finish(err error) error {
+	p := r.playlist
+	if r.ver > p.Version() {
+		p.SetVersion(r.ver)
+	}
+	p.ResetCache()
+	if werr := os.WriteFile(filepath.Join(r.Dir, PlaylistName), p.Encode().Bytes(), 0644); err == nil {
+		err = werr
+	}
+	return err
+}
+
+// download saves the resource at rawurl, or its byte range if limit is
+// positive, to the file name in the directory.
+func (r *Recorder) download(ctx context.Context, rawurl string, limit, offset int, name string) error {
+	req, err := http.NewRequest(http.MethodGet, rawurl, nil)
+	if err != nil {
+		return err
+	}
+	if limit > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, offset+limit-1))
+	}
+	httpClient := r.Client.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var body io.Reader = resp.Body
+	switch {
+	case resp.StatusCode == http.StatusPartialContent:
+	case resp.StatusCode == http.StatusOK && limit > 0: // ranges not supported
+		if _, err = io.CopyN(io.Discard, body, int64(offset)); err != nil {
+			return err
+		}
+		body = io.LimitReader(body, int64(limit))
+	case resp.StatusCode != http.StatusOK:
+		return fmt.Errorf("recorder: GET %s: %s", rawurl, resp.Status)
+	}
+	f, err := os.Create(filepath.Join(r.Dir, name))
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(f, body); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// sameKeys reports whether both sets of keys have the same attributes.
+func sameKeys(a, b []*hls.Key) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if *a[i] != *b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// explicitIVs returns copies of the keys where AES-128 keys without IV have
+// the one derived from the media sequence number, see section 5.2 of
+// RFC 8216, and reports whether it set any.
+func explicitIVs(keys []*hls.Key, seqID int) ([]*hls.Key, bool) {
+	derived := false
+	ivKeys := make([]*hls.Key, len(keys))
+	for i, k := range keys {
+		key := *k
+		if key.Method == hls.KeyMethodAES128 && key.IV == "" {
+			key.IV = fmt.Sprintf("0x%032X", seqID)
+			derived = true
+		}
+		ivKeys[i] = &key
+	}
+	return ivKeys, derived
+}
+
+// ext returns the extension of the path of the URL.
+func ext(rawurl string) string {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return ""
+	}
+	return path.Ext(u.Path)
+}
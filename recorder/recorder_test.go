@@ -0,0 +1,262 @@
+package recorder_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ShevaXu/hls"
+	"github.com/ShevaXu/hls/recorder"
+)
+
+// liveServer serves a live playlist gaining a segment every reload, with a
+// discontinuity before segment 3, and the segments themselves.
+type liveServer struct {
+	mu      sync.Mutex
+	reloads int
+	end     int // reloads before EXT-X-ENDLIST
+}
+
+func (s *liveServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if strings.HasSuffix(r.URL.Path, ".ts") || strings.HasSuffix(r.URL.Path, ".mp4") {
+		w.Write([]byte(r.URL.Path))
+		return
+	}
+	s.mu.Lock()
+	n := s.reloads
+	s.reloads++
+	s.mu.Unlock()
+	last := 2 + n
+	first := last - 2
+	var b strings.Builder
+	fmt.Fprintf(&b, "#EXTM3U\n#EXT-X-VERSION:6\n#EXT-X-MEDIA-SEQUENCE:%d\n#EXT-X-TARGETDURATION:0.05\n", first)
+	b.WriteString("#EXT-X-KEY:METHOD=AES-128,URI=\"https://keys.example.com/1.key\",IV=0x00000000000000000000000000000001\n")
+	b.WriteString("#EXT-X-MAP:URI=\"init.mp4\"\n")
+	for i := first; i <= last; i++ {
+		if i == 3 {
+			b.WriteString("#EXT-X-DISCONTINUITY\n")
+		}
+		if i == first {
+			b.WriteString("#EXT-X-PROGRAM-DATE-TIME:2020-01-01T00:00:00Z\n")
+		}
+		fmt.Fprintf(&b, "#EXTINF:0.050,\nseg%d.ts\n", i)
+	}
+	if n >= s.end {
+		b.WriteString("#EXT-X-ENDLIST\n")
+	}
+	w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+	w.Write([]byte(b.String()))
+}
+
+func TestRecord(t *testing.T) {
+	srv := httptest.NewServer(&liveServer{end: 3})
+	defer srv.Close()
+	dir := t.TempDir()
+	r, err := recorder.New(srv.URL+"/live/index.m3u8", srv.Client(), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Client.MinReload = 10 * time.Millisecond
+	var recorded int
+	r.OnSegment = func(*hls.MediaSegment) { recorded++ }
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	p, err := r.Record(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.Closed || p.MediaType != hls.MediaTypeVOD {
+		t.Error("Expected a closed VOD playlist")
+	}
+	if p.Count() != 6 || recorded != 6 {
+		t.Fatalf("Expected 6 segments, got %d (%d recorded)", p.Count(), recorded)
+	}
+	if p.Version() != 6 {
+		t.Errorf("Expected version 6, got %d", p.Version())
+	}
+	for i, seg := range p.Segments[:p.Count()] {
+		name := fmt.Sprintf("%d.ts", i)
+		if seg.URI != name {
+			t.Errorf("Segment %d: expected URI %s, got %s", i, name, seg.URI)
+		}
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil || string(data) != fmt.Sprintf("/live/seg%d.ts", i) {
+			t.Errorf("Segment %d: unexpected content %q %v", i, data, err)
+		}
+		if seg.Discontinuity != (i == 3) {
+			t.Errorf("Segment %d: unexpected discontinuity %v", i, seg.Discontinuity)
+		}
+		if (seg.Key != nil) != (i == 0) || (seg.Map != nil) != (i == 0) {
+			t.Errorf("Segment %d: unexpected key %v or map %v", i, seg.Key, seg.Map)
+		}
+	}
+	first := p.Segments[0]
+	if first.Key.URI != "https://keys.example.com/1.key" || first.Map.URI != "init-0.mp4" {
+		t.Errorf("Unexpected key %+v or map %+v", first.Key, first.Map)
+	}
+	if first.ProgramDateTime.IsZero() {
+		t.Error("Expected the program date time to be kept")
+	}
+	data, err := os.ReadFile(filepath.Join(dir, recorder.PlaylistName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "#EXT-X-ENDLIST") || strings.Count(string(data), "#EXT-X-KEY") != 1 {
+		t.Errorf("Unexpected playlist:\n%s", data)
+	}
+}
+
+func TestRecordMissedSegments(t *testing.T) {
+	var mu sync.Mutex
+	reloads := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, ".ts") {
+			return
+		}
+		mu.Lock()
+		first := reloads * 5 // the window slides past unseen segments
+		reloads++
+		mu.Unlock()
+		fmt.Fprintf(w, "#EXTM3U\n#EXT-X-MEDIA-SEQUENCE:%d\n#EXT-X-TARGETDURATION:0.05\n", first)
+		for i := first; i < first+2; i++ {
+			fmt.Fprintf(w, "#EXTINF:0.050,\nseg%d.ts\n", i)
+		}
+		if first > 0 {
+			fmt.Fprint(w, "#EXT-X-ENDLIST\n")
+		}
+	}))
+	defer srv.Close()
+	r, err := recorder.New(srv.URL+"/index.m3u8", srv.Client(), t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Client.MinReload = 10 * time.Millisecond
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	p, err := r.Record(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Count() != 4 {
+		t.Fatalf("Expected 4 segments, got %d", p.Count())
+	}
+	if seg := p.Segments[2]; seg.SeqID != 5 || !seg.Discontinuity {
+		t.Errorf("Expected a discontinuity at segment 5, got %d %v", seg.SeqID, seg.Discontinuity)
+	}
+}
+
+func TestRecordSequence(t *testing.T) {
+	var mu sync.Mutex
+	reloads := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, ".ts") {
+			w.Write([]byte(r.URL.Path))
+			return
+		}
+		mu.Lock()
+		first := 10 + reloads*5 // the window slides past unseen segments
+		reloads++
+		mu.Unlock()
+		fmt.Fprintf(w, "#EXTM3U\n#EXT-X-MEDIA-SEQUENCE:%d\n#EXT-X-TARGETDURATION:0.05\n", first)
+		fmt.Fprint(w, "#EXT-X-KEY:METHOD=AES-128,URI=\"https://keys.example.com/1.key\"\n")
+		for i := first; i < first+2; i++ {
+			fmt.Fprintf(w, "#EXTINF:0.050,\nseg%d.ts\n", i)
+		}
+		if first > 10 {
+			fmt.Fprint(w, "#EXT-X-ENDLIST\n")
+		}
+	}))
+	defer srv.Close()
+	dir := t.TempDir()
+	r, err := recorder.New(srv.URL+"/index.m3u8", srv.Client(), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Client.MinReload = 10 * time.Millisecond
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	p, err := r.Record(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.SeqNo != 10 || p.Count() != 4 {
+		t.Fatalf("Expected 4 segments from 10, got %d from %d", p.Count(), p.SeqNo)
+	}
+	for i, seqID := range []int{10, 11, 15, 16} {
+		seg := p.Segments[i]
+		name := fmt.Sprintf("%d.ts", 10+i)
+		if seg.URI != name {
+			t.Errorf("Segment %d: expected URI %s, got %s", seqID, name, seg.URI)
+		}
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil || string(data) != fmt.Sprintf("/seg%d.ts", seqID) {
+			t.Errorf("Segment %d: unexpected content %q %v", seqID, data, err)
+		}
+		// the IV of the live segment, not of its position in the recording
+		if iv := fmt.Sprintf("0x%032X", seqID); seg.Key == nil || seg.Key.IV != iv {
+			t.Errorf("Segment %d: expected key with IV %s, got %+v", seqID, iv, seg.Key)
+		}
+	}
+	data, err := os.ReadFile(filepath.Join(dir, recorder.PlaylistName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "#EXT-X-MEDIA-SEQUENCE:10\n") || strings.Count(string(data), "#EXT-X-KEY") != 4 {
+		t.Errorf("Unexpected playlist:\n%s", data)
+	}
+}
+
+func TestRecordByteRanges(t *testing.T) {
+	media := make([]byte, 300)
+	for i := range media {
+		media[i] = byte(i)
+	}
+	var mu sync.Mutex
+	reloads := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, ".ts") {
+			http.ServeContent(w, r, "media.ts", time.Time{}, bytes.NewReader(media))
+			return
+		}
+		mu.Lock()
+		n := reloads
+		reloads++
+		mu.Unlock()
+		if n == 0 {
+			fmt.Fprint(w, "#EXTM3U\n#EXT-X-VERSION:4\n#EXT-X-TARGETDURATION:0.05\n#EXT-X-BYTERANGE:100@0\n#EXTINF:0.050,\nmedia.ts\n#EXT-X-BYTERANGE:100\n#EXTINF:0.050,\nmedia.ts\n")
+			return
+		}
+		// the range of segment 2 follows the one of segment 1, segment 3 starts over explicitly
+		fmt.Fprint(w, "#EXTM3U\n#EXT-X-VERSION:4\n#EXT-X-MEDIA-SEQUENCE:1\n#EXT-X-TARGETDURATION:0.05\n#EXT-X-BYTERANGE:100@100\n#EXTINF:0.050,\nmedia.ts\n#EXT-X-BYTERANGE:100\n#EXTINF:0.050,\nmedia.ts\n#EXT-X-BYTERANGE:100@0\n#EXTINF:0.050,\nmedia.ts\n#EXT-X-ENDLIST\n")
+	}))
+	defer srv.Close()
+	dir := t.TempDir()
+	r, err := recorder.New(srv.URL+"/index.m3u8", srv.Client(), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Client.MinReload = 10 * time.Millisecond
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	p, err := r.Record(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Count() != 4 {
+		t.Fatalf("Expected 4 segments, got %d", p.Count())
+	}
+	for i, offset := range []int{0, 100, 200, 0} {
+		data, err := os.ReadFile(filepath.Join(dir, fmt.Sprintf("%d.ts", i)))
+		if err != nil || !bytes.Equal(data, media[offset:offset+100]) {
+			t.Errorf("Segment %d: unexpected content %v", i, err)
+		}
+	}
+}
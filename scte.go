@@ -0,0 +1,304 @@
+package hls
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ShevaXu/hls/scte35"
+)
+
+// BreakDuration returns the duration in seconds of the break signalled by
+// the cue, taken from the tag attributes or else from the binary cue.
+// It returns zero if the duration is unknown.
+func (s *SCTE) BreakDuration() float64 {
+	switch s.Syntax {
+	case Syntax672014, SyntaxAdobeSplicePoint:
+		// TIME is not a duration in these syntaxes
+	default:
+		if s.Time > 0 {
+			return s.Time
+		}
+	}
+	if s.Cue != "" {
+		if sis, err := scte35.DecodeString(s.Cue); err == nil {
+			return sis.Duration().Seconds()
+		}
+	}
+	return 0
+}
+
+// cueType returns the cue type, which single tag syntaxes only carry
+// in the binary cue.
+func (s *SCTE) cueType() SCTE35CueType {
+	if s.Syntax != Syntax672014 && s.Syntax != SyntaxAdobeSplicePoint {
+		return s.CueType
+	}
+	sis, err := scte35.DecodeString(s.Cue)
+	if err != nil {
+		return s.CueType
+	}
+	if sis.SpliceInsert != nil && !sis.SpliceInsert.EventCancel && !sis.SpliceInsert.OutOfNetwork {
+		return SCTE35CueEnd
+	}
+	for _, d := range sis.SegmentationDescriptors() {
+		switch d.TypeID {
+		case scte35.SegmentationProgramEnd, scte35.SegmentationChapterEnd, scte35.SegmentationBreakEnd,
+			scte35.SegmentationProviderAdEnd, scte35.SegmentationDistributorAdEnd,
+			scte35.SegmentationProviderPOEnd, scte35.SegmentationDistributorPOEnd,
+			scte35.SegmentationProviderOverlayPOEnd, scte35.SegmentationDistributorOverlayPOEnd,
+			scte35.SegmentationUnscheduledEventEnd, scte35.SegmentationAlternateContentOpportunityEnd,
+			scte35.SegmentationProviderAdBlockEnd, scte35.SegmentationDistributorAdBlockEnd:
+			return SCTE35CueEnd
+		}
+	}
+	return SCTE35CueStart
+}
+
+// hasMidCues reports whether the syntax marks segments inside a break.
+func (syntax SCTE35Syntax) hasMidCues() bool {
+	switch syntax {
+	case SyntaxOATCLS, SyntaxElemental, SyntaxElementalSCTE35:
+		return true
+	}
+	return false
+}
+
+// hasEndCues reports whether the syntax marks the end of a break with its own tag.
+func (syntax SCTE35Syntax) hasEndCues() bool {
+	return syntax.hasMidCues() || syntax == SyntaxAdobe
+}
+
+// adBreak tracks an ad break while walking or appending segments.
+type adBreak struct {
+	cue       string
+	id        string
+	duration  float64
+	elapsed   float64
+	startDate time.Time
+	rangeID   string // ID of the EXT-X-DATERANGE tags
+	started   bool   // the cue out was emitted
+	ending    bool   // the cue in is due
+	syntax    SCTE35Syntax
+	dateRange bool // add EXT-X-DATERANGE tags, see StartAdBreak
+}
+
+// expired reports whether the break reached its duration.
+func (b *adBreak) expired() bool {
+	return b.duration > 0 && b.elapsed >= b.duration-0.001
+}
+
+// scte returns the cue of the given type for the break in the syntax,
+// or nil if the syntax has no tag for it. The end cue keeps endCue if set.
+func (b *adBreak) scte(syntax SCTE35Syntax, cueType SCTE35CueType, endCue string) *SCTE {
+	s := &SCTE{Syntax: syntax, CueType: cueType, ID: b.id}
+	switch cueType {
+	case SCTE35CueStart:
+		s.Cue = b.cue
+		switch syntax {
+		case Syntax672014, SyntaxAdobeSplicePoint:
+		default:
+			s.Time = b.duration
+		}
+	case SCTE35CueMid:
+		if !syntax.hasMidCues() {
+			return nil
+		}
+		s.Cue = b.cue
+		s.Time = b.duration
+		s.Elapsed = b.elapsed
+	case SCTE35CueEnd:
+		s.Cue = endCue
+		if !syntax.hasEndCues() && endCue == "" {
+			return nil
+		}
+		if syntax == SyntaxElementalSCTE35 && s.Cue == "" {
+			s.Cue = b.cue
+		}
+	}
+	if syntax == SyntaxElemental {
+		s.Cue, s.ID = "", ""
+	}
+	return s
+}
+
+// ConvertSCTE35 rewrites the SCTE-35 cues of the segments from one syntax
+// to another. Segments inside a break get mid cues with their elapsed time
+// and the first segment after the break gets an end cue, if the target syntax
+// has such tags and the source does not provide them; cues which the target
+// syntax can not represent are dropped.
+// If dateRange is true, EXT-X-DATERANGE tags with SCTE35-OUT and SCTE35-IN
+// are added at the start and at the end of every break as well,
+// which requires EXT-X-PROGRAM-DATE-TIME in the playlist.
+// This operation does reset playlist cache.
+func (p *MediaPlaylist) ConvertSCTE35(from, to SCTE35Syntax, dateRange bool) error {
+	segs := p.segments()
+	var times []time.Time
+	if dateRange {
+		times = programDateTimes(segs)
+	}
+	var brk *adBreak
+	for i, seg := range segs {
+		s := seg.SCTE
+		switch {
+		case s != nil && s.Syntax == from:
+			switch s.cueType() {
+			case SCTE35CueStart:
+				brk = &adBreak{cue: s.Cue, id: s.ID, duration: s.BreakDuration()}
+				if dateRange {
+					if times[i].IsZero() {
+						return errors.New("EXT-X-DATERANGE requires EXT-X-PROGRAM-DATE-TIME")
+					}
+					brk.startDate = times[i]
+					if brk.rangeID = brk.id; brk.rangeID == "" {
+						brk.rangeID = "splice-" + strconv.Itoa(p.SeqNo+i)
+					}
+					seg.DateRanges = append(seg.DateRanges, &DateRange{
+						ID:              brk.rangeID,
+						StartDate:       brk.startDate,
+						PlannedDuration: brk.duration,
+						SCTE35Out:       cueToHex(brk.cue),
+					})
+				}
+				seg.SCTE = brk.scte(to, SCTE35CueStart, "")
+			case SCTE35CueMid:
+				if brk == nil { // the playlist starts inside a break
+					brk = &adBreak{cue: s.Cue, id: s.ID, duration: s.BreakDuration(), elapsed: s.Elapsed}
+				}
+				seg.SCTE = brk.scte(to, SCTE35CueMid, "")
+			case SCTE35CueEnd:
+				if brk == nil {
+					brk = &adBreak{id: s.ID}
+				}
+				brk.end(seg, to, s.Cue, dateRange)
+				brk = nil
+			}
+		case s == nil && brk != nil:
+			if brk.expired() {
+				brk.end(seg, to, "", dateRange)
+				brk = nil
+			} else {
+				seg.SCTE = brk.scte(to, SCTE35CueMid, "")
+			}
+		}
+		if brk != nil {
+			brk.elapsed += seg.Duration
+		}
+	}
+	p.buf.Reset()
+	return nil
+}
+
+// end sets the end cue and the closing EXT-X-DATERANGE on the segment.
+func (b *adBreak) end(seg *MediaSegment, to SCTE35Syntax, cue string, dateRange bool) {
+	seg.SCTE = b.scte(to, SCTE35CueEnd, cue)
+	if dateRange && !b.startDate.IsZero() {
+		seg.DateRanges = append(seg.DateRanges, &DateRange{
+			ID:        b.rangeID,
+			StartDate: b.startDate,
+			Duration:  b.elapsed,
+			SCTE35In:  cueToHex(cue),
+		})
+	}
+}
+
+// cueToHex converts a base64 cue to the hex form of EXT-X-DATERANGE.
+func cueToHex(cue string) string {
+	if cue == "" || strings.HasPrefix(cue, "0x") || strings.HasPrefix(cue, "0X") {
+		return cue
+	}
+	b, err := base64.StdEncoding.DecodeString(cue)
+	if err != nil {
+		return ""
+	}
+	return "0x" + strings.ToUpper(hex.EncodeToString(b))
+}
+
+// StartAdBreak starts an ad break of the duration in seconds with the
+// SCTE-35 cue. Segments appended from now on are tagged in the syntax:
+// the first one with the cue out, the following ones with cue out
+// continuations carrying the elapsed time if the syntax has them, and the
+// first one after the break expires or EndAdBreak is called with the cue
+// in if the syntax has it. If dateRange is true, EXT-X-DATERANGE tags with
+// SCTE35-OUT and SCTE35-IN are added at the start and at the end of the
+// break as well, provided the segments have EXT-X-PROGRAM-DATE-TIME.
+func (p *MediaPlaylist) StartAdBreak(syntax SCTE35Syntax, duration float64, cue string, dateRange bool) error {
+	if p.adBreak != nil {
+		return errors.New("ad break in progress")
+	}
+	p.adBreak = &adBreak{cue: cue, duration: duration, syntax: syntax, dateRange: dateRange}
+	return nil
+}
+
+// EndAdBreak ends the ad break, the next appended segment gets the cue in.
+func (p *MediaPlaylist) EndAdBreak() {
+	if p.adBreak == nil {
+		return
+	}
+	if !p.adBreak.started {
+		p.adBreak = nil
+		return
+	}
+	p.adBreak.ending = true
+}
+
+// InAdBreak reports whether an ad break started by StartAdBreak is in progress.
+func (p *MediaPlaylist) InAdBreak() bool {
+	return p.adBreak != nil
+}
+
+// tagAdBreak sets the cue of the ad break in progress on an appended segment.
+// Segments which already have a cue keep it, the break goes on with them.
+func (p *MediaPlaylist) tagAdBreak(seg *MediaSegment) {
+	b := p.adBreak
+	if b == nil {
+		return
+	}
+	cued := seg.SCTE
+	switch {
+	case !b.started:
+		seg.SCTE = b.scte(b.syntax, SCTE35CueStart, "")
+		b.started = true
+		if b.dateRange {
+			if b.startDate = p.nextProgramDateTime(seg); !b.startDate.IsZero() {
+				if b.rangeID = b.id; b.rangeID == "" {
+					b.rangeID = "splice-" + strconv.Itoa(p.SeqNo+p.count)
+				}
+				seg.DateRanges = append(seg.DateRanges, &DateRange{
+					ID:              b.rangeID,
+					StartDate:       b.startDate,
+					PlannedDuration: b.duration,
+					SCTE35Out:       cueToHex(b.cue),
+				})
+			}
+		}
+	case b.ending || b.expired():
+		b.end(seg, b.syntax, "", b.dateRange)
+		p.adBreak = nil
+	default:
+		seg.SCTE = b.scte(b.syntax, SCTE35CueMid, "")
+	}
+	if cued != nil {
+		seg.SCTE = cued
+	}
+	if p.adBreak != nil {
+		b.elapsed += seg.Duration
+	}
+}
+
+// nextProgramDateTime returns the wall-clock start of the segment about to
+// be appended, zero if unknown.
+func (p *MediaPlaylist) nextProgramDateTime(seg *MediaSegment) time.Time {
+	if !seg.ProgramDateTime.IsZero() || p.count == 0 {
+		return seg.ProgramDateTime
+	}
+	segs := p.segments()
+	last := len(segs) - 1
+	if t := programDateTimes(segs)[last]; !t.IsZero() {
+		return t.Add(seconds(segs[last].Duration))
+	}
+	return time.Time{}
+}
@@ -0,0 +1,108 @@
+package scte35
+
+// bitReader reads big-endian bit fields; the first error sticks.
+type bitReader struct {
+	b   []byte
+	pos int // in bits
+	err error
+}
+
+func (r *bitReader) uint(n int) uint64 {
+	if r.err != nil {
+		return 0
+	}
+	if r.pos+n > len(r.b)*8 {
+		r.err = ErrShortSection
+		return 0
+	}
+	var v uint64
+	for i := 0; i < n; i++ {
+		v = v<<1 | uint64(r.b[r.pos>>3]>>(7-uint(r.pos&7))&1)
+		r.pos++
+	}
+	return v
+}
+
+func (r *bitReader) flag() bool {
+	return r.uint(1) == 1
+}
+
+// bytes reads n whole bytes, the reader must be byte aligned.
+func (r *bitReader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	start := r.pos >> 3
+	if start+n > len(r.b) {
+		r.err = ErrShortSection
+		return nil
+	}
+	r.pos += n * 8
+	return append([]byte(nil), r.b[start:start+n]...)
+}
+
+// offset returns the current position in bytes.
+func (r *bitReader) offset() int {
+	return r.pos >> 3
+}
+
+// bitWriter writes big-endian bit fields.
+type bitWriter struct {
+	b   []byte
+	pos int // in bits
+}
+
+func (w *bitWriter) put(v uint64, n int) {
+	for i := n - 1; i >= 0; i-- {
+		if w.pos&7 == 0 {
+			w.b = append(w.b, 0)
+		}
+		if v>>uint(i)&1 == 1 {
+			w.b[len(w.b)-1] |= 1 << (7 - uint(w.pos&7))
+		}
+		w.pos++
+	}
+}
+
+func (w *bitWriter) flag(f bool) {
+	if f {
+		w.put(1, 1)
+	} else {
+		w.put(0, 1)
+	}
+}
+
+// reserved writes n reserved bits which are set to one.
+func (w *bitWriter) reserved(n int) {
+	w.put(1<<uint(n)-1, n)
+}
+
+// bytes writes whole bytes, the writer must be byte aligned.
+func (w *bitWriter) bytes(b []byte) {
+	w.b = append(w.b, b...)
+	w.pos += len(b) * 8
+}
+
+var crcTable = func() (t [256]uint32) {
+	for i := range t {
+		c := uint32(i) << 24
+		for j := 0; j < 8; j++ {
+			if c&0x80000000 != 0 {
+				c = c<<1 ^ 0x04C11DB7
+			} else {
+				c <<= 1
+			}
+		}
+		t[i] = c
+	}
+	return
+}()
+
+// crc32 computes the CRC_32 of MPEG-2 systems (ISO/IEC 13818-1 Annex A).
+func crc32(b []byte) uint32 {
+	c := uint32(0xFFFFFFFF)
+	for _, v := range b {
+		c = c<<8 ^ crcTable[byte(c>>24)^v]
+	}
+	return c
+}
@@ -0,0 +1,176 @@
+package scte35
+
+// Decode parses a splice_info_section and checks its CRC_32.
+func Decode(b []byte) (*SpliceInfoSection, error) {
+	if len(b) < 3 {
+		return nil, ErrShortSection
+	}
+	if b[0] != TableID {
+		return nil, ErrTableID
+	}
+	end := 3 + (int(b[1]&0x0F)<<8 | int(b[2]))
+	if end > len(b) {
+		return nil, ErrShortSection
+	}
+	b = b[:end]
+	if crc32(b) != 0 {
+		return nil, ErrCRC
+	}
+
+	s := new(SpliceInfoSection)
+	r := &bitReader{b: b}
+	r.uint(8) // table_id
+	r.uint(1) // section_syntax_indicator
+	r.uint(1) // private_indicator
+	s.SAPType = uint8(r.uint(2))
+	r.uint(12) // section_length
+	s.ProtocolVersion = uint8(r.uint(8))
+	if r.flag() {
+		return nil, ErrEncrypted
+	}
+	r.uint(6) // encryption_algorithm
+	s.PTSAdjustment = r.uint(33)
+	s.CWIndex = uint8(r.uint(8))
+	s.Tier = uint16(r.uint(12))
+	cmdLen := int(r.uint(12))
+	s.CommandType = CommandType(r.uint(8))
+	start := r.offset()
+	switch s.CommandType {
+	case CommandSpliceNull:
+	case CommandSpliceInsert:
+		s.SpliceInsert = decodeSpliceInsert(r)
+	case CommandTimeSignal:
+		t := decodeSpliceTime(r)
+		s.TimeSignal = &t
+	default:
+		if cmdLen == 0xFFF { // legacy unknown length
+			return nil, ErrShortSection
+		}
+		s.RawCommand = r.bytes(cmdLen)
+	}
+	if cmdLen != 0xFFF {
+		r.pos = (start + cmdLen) * 8
+	}
+
+	loopEnd := int(r.uint(16))
+	loopEnd += r.offset()
+	for r.err == nil && r.offset() < loopEnd {
+		d := new(SpliceDescriptor)
+		d.Tag = uint8(r.uint(8))
+		data := r.bytes(int(r.uint(8)))
+		if r.err != nil {
+			break
+		}
+		if len(data) < 4 {
+			return nil, ErrShortSection
+		}
+		d.Identifier = uint32(data[0])<<24 | uint32(data[1])<<16 | uint32(data[2])<<8 | uint32(data[3])
+		if d.Tag == SegmentationDescriptorTag && d.Identifier == CUEIdentifier {
+			dr := &bitReader{b: data, pos: 32}
+			d.Segmentation = decodeSegmentationDescriptor(dr)
+			if dr.err != nil {
+				return nil, dr.err
+			}
+		} else {
+			d.Data = data[4:]
+		}
+		s.Descriptors = append(s.Descriptors, d)
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	return s, nil
+}
+
+func decodeSpliceTime(r *bitReader) (t SpliceTime) {
+	if t.TimeSpecified = r.flag(); t.TimeSpecified {
+		r.uint(6)
+		t.PTSTime = r.uint(33)
+	} else {
+		r.uint(7)
+	}
+	return
+}
+
+func decodeSpliceInsert(r *bitReader) *SpliceInsert {
+	c := new(SpliceInsert)
+	c.EventID = uint32(r.uint(32))
+	c.EventCancel = r.flag()
+	r.uint(7)
+	if c.EventCancel {
+		return c
+	}
+	c.OutOfNetwork = r.flag()
+	c.ProgramSplice = r.flag()
+	hasDuration := r.flag()
+	c.SpliceImmediate = r.flag()
+	r.uint(4)
+	if c.ProgramSplice && !c.SpliceImmediate {
+		c.SpliceTime = decodeSpliceTime(r)
+	}
+	if !c.ProgramSplice {
+		n := int(r.uint(8))
+		for i := 0; i < n && r.err == nil; i++ {
+			comp := Component{Tag: uint8(r.uint(8))}
+			if !c.SpliceImmediate {
+				comp.SpliceTime = decodeSpliceTime(r)
+			}
+			c.Components = append(c.Components, comp)
+		}
+	}
+	if hasDuration {
+		c.BreakDuration = new(BreakDuration)
+		c.BreakDuration.AutoReturn = r.flag()
+		r.uint(6)
+		c.BreakDuration.Duration = r.uint(33)
+	}
+	c.UniqueProgramID = uint16(r.uint(16))
+	c.AvailNum = uint8(r.uint(8))
+	c.AvailsExpected = uint8(r.uint(8))
+	return c
+}
+
+// decodeSegmentationDescriptor parses the fields following the identifier.
+func decodeSegmentationDescriptor(r *bitReader) *SegmentationDescriptor {
+	d := new(SegmentationDescriptor)
+	d.EventID = uint32(r.uint(32))
+	d.EventCancel = r.flag()
+	r.uint(7)
+	if d.EventCancel {
+		return d
+	}
+	d.ProgramSegmentation = r.flag()
+	d.HasDuration = r.flag()
+	d.DeliveryNotRestricted = r.flag()
+	if d.DeliveryNotRestricted {
+		r.uint(5)
+	} else {
+		d.WebDeliveryAllowed = r.flag()
+		d.NoRegionalBlackout = r.flag()
+		d.ArchiveAllowed = r.flag()
+		d.DeviceRestrictions = uint8(r.uint(2))
+	}
+	if !d.ProgramSegmentation {
+		n := int(r.uint(8))
+		for i := 0; i < n && r.err == nil; i++ {
+			comp := SegmentationComponent{Tag: uint8(r.uint(8))}
+			r.uint(7)
+			comp.PTSOffset = r.uint(33)
+			d.Components = append(d.Components, comp)
+		}
+	}
+	if d.HasDuration {
+		d.Duration = r.uint(40)
+	}
+	d.UPIDType = UPIDType(r.uint(8))
+	d.UPID = r.bytes(int(r.uint(8)))
+	d.TypeID = SegmentationType(r.uint(8))
+	d.SegmentNum = uint8(r.uint(8))
+	d.SegmentsExpected = uint8(r.uint(8))
+	if d.TypeID.hasSubSegments() && r.err == nil && len(r.b)-r.offset() >= 2 {
+		d.HasSubSegments = true
+		d.SubSegmentNum = uint8(r.uint(8))
+		d.SubSegmentsExpected = uint8(r.uint(8))
+	}
+	return d
+}
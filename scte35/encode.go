@@ -0,0 +1,156 @@
+package scte35
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Encode serializes the section with a freshly computed CRC_32.
+// Reserved bits are written as ones.
+func (s *SpliceInfoSection) Encode() ([]byte, error) {
+	cmd := new(bitWriter)
+	switch s.CommandType {
+	case CommandSpliceNull:
+	case CommandSpliceInsert:
+		if s.SpliceInsert == nil {
+			return nil, errors.New("scte35: splice_insert command is nil")
+		}
+		encodeSpliceInsert(cmd, s.SpliceInsert)
+	case CommandTimeSignal:
+		if s.TimeSignal == nil {
+			return nil, errors.New("scte35: time_signal command is nil")
+		}
+		encodeSpliceTime(cmd, *s.TimeSignal)
+	default:
+		cmd.bytes(s.RawCommand)
+	}
+	if len(cmd.b) > 0xFFE {
+		return nil, errors.New("scte35: splice command too long")
+	}
+
+	desc := new(bitWriter)
+	for _, d := range s.Descriptors {
+		data := new(bitWriter)
+		data.put(uint64(d.Identifier), 32)
+		if d.Segmentation != nil {
+			encodeSegmentationDescriptor(data, d.Segmentation)
+		} else {
+			data.bytes(d.Data)
+		}
+		if len(data.b) > 0xFF {
+			return nil, fmt.Errorf("scte35: splice descriptor 0x%02x too long", d.Tag)
+		}
+		desc.put(uint64(d.Tag), 8)
+		desc.put(uint64(len(data.b)), 8)
+		desc.bytes(data.b)
+	}
+
+	length := 11 + len(cmd.b) + 2 + len(desc.b) + 4
+	if length > 0xFFF {
+		return nil, errors.New("scte35: section too long")
+	}
+	w := new(bitWriter)
+	w.put(TableID, 8)
+	w.put(0, 1) // section_syntax_indicator
+	w.put(0, 1) // private_indicator
+	w.put(uint64(s.SAPType), 2)
+	w.put(uint64(length), 12)
+	w.put(uint64(s.ProtocolVersion), 8)
+	w.put(0, 1) // encrypted_packet
+	w.put(0, 6) // encryption_algorithm
+	w.put(s.PTSAdjustment, 33)
+	w.put(uint64(s.CWIndex), 8)
+	w.put(uint64(s.Tier), 12)
+	w.put(uint64(len(cmd.b)), 12)
+	w.put(uint64(s.CommandType), 8)
+	w.bytes(cmd.b)
+	w.put(uint64(len(desc.b)), 16)
+	w.bytes(desc.b)
+	w.put(uint64(crc32(w.b)), 32)
+	return w.b, nil
+}
+
+func encodeSpliceTime(w *bitWriter, t SpliceTime) {
+	w.flag(t.TimeSpecified)
+	if t.TimeSpecified {
+		w.reserved(6)
+		w.put(t.PTSTime, 33)
+	} else {
+		w.reserved(7)
+	}
+}
+
+func encodeSpliceInsert(w *bitWriter, c *SpliceInsert) {
+	w.put(uint64(c.EventID), 32)
+	w.flag(c.EventCancel)
+	w.reserved(7)
+	if c.EventCancel {
+		return
+	}
+	w.flag(c.OutOfNetwork)
+	w.flag(c.ProgramSplice)
+	w.flag(c.BreakDuration != nil)
+	w.flag(c.SpliceImmediate)
+	w.reserved(4)
+	if c.ProgramSplice && !c.SpliceImmediate {
+		encodeSpliceTime(w, c.SpliceTime)
+	}
+	if !c.ProgramSplice {
+		w.put(uint64(len(c.Components)), 8)
+		for _, comp := range c.Components {
+			w.put(uint64(comp.Tag), 8)
+			if !c.SpliceImmediate {
+				encodeSpliceTime(w, comp.SpliceTime)
+			}
+		}
+	}
+	if c.BreakDuration != nil {
+		w.flag(c.BreakDuration.AutoReturn)
+		w.reserved(6)
+		w.put(c.BreakDuration.Duration, 33)
+	}
+	w.put(uint64(c.UniqueProgramID), 16)
+	w.put(uint64(c.AvailNum), 8)
+	w.put(uint64(c.AvailsExpected), 8)
+}
+
+func encodeSegmentationDescriptor(w *bitWriter, d *SegmentationDescriptor) {
+	w.put(uint64(d.EventID), 32)
+	w.flag(d.EventCancel)
+	w.reserved(7)
+	if d.EventCancel {
+		return
+	}
+	w.flag(d.ProgramSegmentation)
+	w.flag(d.HasDuration)
+	w.flag(d.DeliveryNotRestricted)
+	if d.DeliveryNotRestricted {
+		w.reserved(5)
+	} else {
+		w.flag(d.WebDeliveryAllowed)
+		w.flag(d.NoRegionalBlackout)
+		w.flag(d.ArchiveAllowed)
+		w.put(uint64(d.DeviceRestrictions), 2)
+	}
+	if !d.ProgramSegmentation {
+		w.put(uint64(len(d.Components)), 8)
+		for _, comp := range d.Components {
+			w.put(uint64(comp.Tag), 8)
+			w.reserved(7)
+			w.put(comp.PTSOffset, 33)
+		}
+	}
+	if d.HasDuration {
+		w.put(d.Duration, 40)
+	}
+	w.put(uint64(d.UPIDType), 8)
+	w.put(uint64(len(d.UPID)), 8)
+	w.bytes(d.UPID)
+	w.put(uint64(d.TypeID), 8)
+	w.put(uint64(d.SegmentNum), 8)
+	w.put(uint64(d.SegmentsExpected), 8)
+	if d.HasSubSegments {
+		w.put(uint64(d.SubSegmentNum), 8)
+		w.put(uint64(d.SubSegmentsExpected), 8)
+	}
+}
@@ -0,0 +1,406 @@
+// Package scte35 decodes and encodes the binary SCTE-35 splice_info_section
+// carried (base64 or hex encoded) in the cue attributes of HLS ad tags.
+package scte35
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+)
+
+// TableID is the table_id of a splice_info_section.
+const TableID = 0xFC
+
+// CUEIdentifier is the "CUEI" identifier of the splice descriptors
+// defined by SCTE-35.
+const CUEIdentifier = 0x43554549
+
+// TicksPerSecond is the 90kHz clock used for PTS values and durations.
+const TicksPerSecond = 90000
+
+// Errors returned by Decode.
+var (
+	ErrShortSection = errors.New("scte35: section too short")
+	ErrTableID      = errors.New("scte35: invalid table_id")
+	ErrCRC          = errors.New("scte35: CRC_32 mismatch")
+	ErrEncrypted    = errors.New("scte35: encrypted sections are not supported")
+)
+
+// CommandType is the splice_command_type.
+type CommandType uint8
+
+// Splice command types defined in section 9.7.
+const (
+	CommandSpliceNull           CommandType = 0x00
+	CommandSpliceSchedule       CommandType = 0x04
+	CommandSpliceInsert         CommandType = 0x05
+	CommandTimeSignal           CommandType = 0x06
+	CommandBandwidthReservation CommandType = 0x07
+	CommandPrivate              CommandType = 0xFF
+)
+
+// Splice descriptor tags defined in section 10.2.
+const (
+	AvailDescriptorTag        = 0x00
+	DTMFDescriptorTag         = 0x01
+	SegmentationDescriptorTag = 0x02
+	TimeDescriptorTag         = 0x03
+	AudioDescriptorTag        = 0x04
+)
+
+// SpliceInfoSection represents a splice_info_section.
+type SpliceInfoSection struct {
+	SAPType         uint8
+	ProtocolVersion uint8
+	PTSAdjustment   uint64
+	CWIndex         uint8
+	Tier            uint16
+	CommandType     CommandType
+	SpliceInsert    *SpliceInsert // set if CommandType is CommandSpliceInsert
+	TimeSignal      *SpliceTime   // set if CommandType is CommandTimeSignal
+	RawCommand      []byte        // command bytes of the other command types
+	Descriptors     []*SpliceDescriptor
+}
+
+// SpliceTime represents splice_time().
+type SpliceTime struct {
+	TimeSpecified bool
+	PTSTime       uint64
+}
+
+// BreakDuration represents break_duration().
+type BreakDuration struct {
+	AutoReturn bool
+	Duration   uint64 // in 90kHz ticks
+}
+
+// Component is a component of a component splice_insert.
+type Component struct {
+	Tag        uint8
+	SpliceTime SpliceTime
+}
+
+// SpliceInsert represents the splice_insert() command.
+type SpliceInsert struct {
+	EventID         uint32
+	EventCancel     bool
+	OutOfNetwork    bool
+	ProgramSplice   bool
+	SpliceImmediate bool
+	SpliceTime      SpliceTime  // program splice time if ProgramSplice and not SpliceImmediate
+	Components      []Component // used if not ProgramSplice
+	BreakDuration   *BreakDuration
+	UniqueProgramID uint16
+	AvailNum        uint8
+	AvailsExpected  uint8
+}
+
+// SpliceDescriptor represents a splice_descriptor() of the descriptor loop.
+type SpliceDescriptor struct {
+	Tag          uint8
+	Identifier   uint32
+	Segmentation *SegmentationDescriptor // set for segmentation descriptors with CUEIdentifier
+	Data         []byte                  // private bytes after the identifier of other descriptors
+}
+
+// SegmentationComponent is a component of a component segmentation descriptor.
+type SegmentationComponent struct {
+	Tag       uint8
+	PTSOffset uint64
+}
+
+// SegmentationDescriptor represents the segmentation_descriptor().
+type SegmentationDescriptor struct {
+	EventID               uint32
+	EventCancel           bool
+	ProgramSegmentation   bool
+	HasDuration           bool
+	DeliveryNotRestricted bool
+	WebDeliveryAllowed    bool
+	NoRegionalBlackout    bool
+	ArchiveAllowed        bool
+	DeviceRestrictions    uint8
+	Components            []SegmentationComponent // used if not ProgramSegmentation
+	Duration              uint64                  // in 90kHz ticks, valid if HasDuration
+	UPIDType              UPIDType
+	UPID                  []byte
+	TypeID                SegmentationType
+	SegmentNum            uint8
+	SegmentsExpected      uint8
+	HasSubSegments        bool
+	SubSegmentNum         uint8
+	SubSegmentsExpected   uint8
+}
+
+// UPIDType is the segmentation_upid_type.
+type UPIDType uint8
+
+// UPID types defined in table 21.
+const (
+	UPIDNotUsed        UPIDType = 0x00
+	UPIDUserDefined    UPIDType = 0x01
+	UPIDISCI           UPIDType = 0x02
+	UPIDAdID           UPIDType = 0x03
+	UPIDUMID           UPIDType = 0x04
+	UPIDISANDeprecated UPIDType = 0x05
+	UPIDISAN           UPIDType = 0x06
+	UPIDTID            UPIDType = 0x07
+	UPIDTI             UPIDType = 0x08
+	UPIDADI            UPIDType = 0x09
+	UPIDEIDR           UPIDType = 0x0A
+	UPIDATSC           UPIDType = 0x0B
+	UPIDMPU            UPIDType = 0x0C
+	UPIDMID            UPIDType = 0x0D
+	UPIDADSInfo        UPIDType = 0x0E
+	UPIDURI            UPIDType = 0x0F
+	UPIDUUID           UPIDType = 0x10
+	UPIDSCR            UPIDType = 0x11
+)
+
+var upidNames = map[UPIDType]string{
+	UPIDNotUsed:        "Not Used",
+	UPIDUserDefined:    "User Defined",
+	UPIDISCI:           "ISCI",
+	UPIDAdID:           "Ad-ID",
+	UPIDUMID:           "UMID",
+	UPIDISANDeprecated: "ISAN (deprecated)",
+	UPIDISAN:           "ISAN",
+	UPIDTID:            "TID",
+	UPIDTI:             "TI",
+	UPIDADI:            "ADI",
+	UPIDEIDR:           "EIDR",
+	UPIDATSC:           "ATSC Content Identifier",
+	UPIDMPU:            "MPU",
+	UPIDMID:            "MID",
+	UPIDADSInfo:        "ADS Information",
+	UPIDURI:            "URI",
+	UPIDUUID:           "UUID",
+	UPIDSCR:            "SCR",
+}
+
+func (t UPIDType) String() string {
+	if name, ok := upidNames[t]; ok {
+		return name
+	}
+	return "Reserved"
+}
+
+// textual reports whether the UPID type carries printable characters.
+func (t UPIDType) textual() bool {
+	switch t {
+	case UPIDISCI, UPIDAdID, UPIDTID, UPIDADI, UPIDADSInfo, UPIDURI, UPIDSCR:
+		return true
+	}
+	return false
+}
+
+// SegmentationType is the segmentation_type_id.
+type SegmentationType uint8
+
+// Segmentation types defined in table 22.
+const (
+	SegmentationNotIndicated                     SegmentationType = 0x00
+	SegmentationContentIdentification            SegmentationType = 0x01
+	SegmentationProgramStart                     SegmentationType = 0x10
+	SegmentationProgramEnd                       SegmentationType = 0x11
+	SegmentationProgramEarlyTermination          SegmentationType = 0x12
+	SegmentationProgramBreakaway                 SegmentationType = 0x13
+	SegmentationProgramResumption                SegmentationType = 0x14
+	SegmentationProgramRunoverPlanned            SegmentationType = 0x15
+	SegmentationProgramRunoverUnplanned          SegmentationType = 0x16
+	SegmentationProgramOverlapStart              SegmentationType = 0x17
+	SegmentationProgramBlackoutOverride          SegmentationType = 0x18
+	SegmentationProgramStartInProgress           SegmentationType = 0x19
+	SegmentationChapterStart                     SegmentationType = 0x20
+	SegmentationChapterEnd                       SegmentationType = 0x21
+	SegmentationBreakStart                       SegmentationType = 0x22
+	SegmentationBreakEnd                         SegmentationType = 0x23
+	SegmentationOpeningCreditStart               SegmentationType = 0x24
+	SegmentationOpeningCreditEnd                 SegmentationType = 0x25
+	SegmentationClosingCreditStart               SegmentationType = 0x26
+	SegmentationClosingCreditEnd                 SegmentationType = 0x27
+	SegmentationProviderAdStart                  SegmentationType = 0x30
+	SegmentationProviderAdEnd                    SegmentationType = 0x31
+	SegmentationDistributorAdStart               SegmentationType = 0x32
+	SegmentationDistributorAdEnd                 SegmentationType = 0x33
+	SegmentationProviderPOStart                  SegmentationType = 0x34
+	SegmentationProviderPOEnd                    SegmentationType = 0x35
+	SegmentationDistributorPOStart               SegmentationType = 0x36
+	SegmentationDistributorPOEnd                 SegmentationType = 0x37
+	SegmentationProviderOverlayPOStart           SegmentationType = 0x38
+	SegmentationProviderOverlayPOEnd             SegmentationType = 0x39
+	SegmentationDistributorOverlayPOStart        SegmentationType = 0x3A
+	SegmentationDistributorOverlayPOEnd          SegmentationType = 0x3B
+	SegmentationProviderPromoStart               SegmentationType = 0x3C
+	SegmentationProviderPromoEnd                 SegmentationType = 0x3D
+	SegmentationDistributorPromoStart            SegmentationType = 0x3E
+	SegmentationDistributorPromoEnd              SegmentationType = 0x3F
+	SegmentationUnscheduledEventStart            SegmentationType = 0x40
+	SegmentationUnscheduledEventEnd              SegmentationType = 0x41
+	SegmentationAlternateContentOpportunityStart SegmentationType = 0x42
+	SegmentationAlternateContentOpportunityEnd   SegmentationType = 0x43
+	SegmentationProviderAdBlockStart             SegmentationType = 0x44
+	SegmentationProviderAdBlockEnd               SegmentationType = 0x45
+	SegmentationDistributorAdBlockStart          SegmentationType = 0x46
+	SegmentationDistributorAdBlockEnd            SegmentationType = 0x47
+	SegmentationNetworkStart                     SegmentationType = 0x50
+	SegmentationNetworkEnd                       SegmentationType = 0x51
+)
+
+var segmentationNames = map[SegmentationType]string{
+	SegmentationNotIndicated:                     "Not Indicated",
+	SegmentationContentIdentification:            "Content Identification",
+	SegmentationProgramStart:                     "Program Start",
+	SegmentationProgramEnd:                       "Program End",
+	SegmentationProgramEarlyTermination:          "Program Early Termination",
+	SegmentationProgramBreakaway:                 "Program Breakaway",
+	SegmentationProgramResumption:                "Program Resumption",
+	SegmentationProgramRunoverPlanned:            "Program Runover Planned",
+	SegmentationProgramRunoverUnplanned:          "Program Runover Unplanned",
+	SegmentationProgramOverlapStart:              "Program Overlap Start",
+	SegmentationProgramBlackoutOverride:          "Program Blackout Override",
+	SegmentationProgramStartInProgress:           "Program Start - In Progress",
+	SegmentationChapterStart:                     "Chapter Start",
+	SegmentationChapterEnd:                       "Chapter End",
+	SegmentationBreakStart:                       "Break Start",
+	SegmentationBreakEnd:                         "Break End",
+	SegmentationOpeningCreditStart:               "Opening Credit Start",
+	SegmentationOpeningCreditEnd:                 "Opening Credit End",
+	SegmentationClosingCreditStart:               "Closing Credit Start",
+	SegmentationClosingCreditEnd:                 "Closing Credit End",
+	SegmentationProviderAdStart:                  "Provider Advertisement Start",
+	SegmentationProviderAdEnd:                    "Provider Advertisement End",
+	SegmentationDistributorAdStart:               "Distributor Advertisement Start",
+	SegmentationDistributorAdEnd:                 "Distributor Advertisement End",
+	SegmentationProviderPOStart:                  "Provider Placement Opportunity Start",
+	SegmentationProviderPOEnd:                    "Provider Placement Opportunity End",
+	SegmentationDistributorPOStart:               "Distributor Placement Opportunity Start",
+	SegmentationDistributorPOEnd:                 "Distributor Placement Opportunity End",
+	SegmentationProviderOverlayPOStart:           "Provider Overlay Placement Opportunity Start",
+	SegmentationProviderOverlayPOEnd:             "Provider Overlay Placement Opportunity End",
+	SegmentationDistributorOverlayPOStart:        "Distributor Overlay Placement Opportunity Start",
+	SegmentationDistributorOverlayPOEnd:          "Distributor Overlay Placement Opportunity End",
+	SegmentationProviderPromoStart:               "Provider Promo Start",
+	SegmentationProviderPromoEnd:                 "Provider Promo End",
+	SegmentationDistributorPromoStart:            "Distributor Promo Start",
+	SegmentationDistributorPromoEnd:              "Distributor Promo End",
+	SegmentationUnscheduledEventStart:            "Unscheduled Event Start",
+	SegmentationUnscheduledEventEnd:              "Unscheduled Event End",
+	SegmentationAlternateContentOpportunityStart: "Alternate Content Opportunity Start",
+	SegmentationAlternateContentOpportunityEnd:   "Alternate Content Opportunity End",
+	SegmentationProviderAdBlockStart:             "Provider Ad Block Start",
+	SegmentationProviderAdBlockEnd:               "Provider Ad Block End",
+	SegmentationDistributorAdBlockStart:          "Distributor Ad Block Start",
+	SegmentationDistributorAdBlockEnd:            "Distributor Ad Block End",
+	SegmentationNetworkStart:                     "Network Start",
+	SegmentationNetworkEnd:                       "Network End",
+}
+
+func (t SegmentationType) String() string {
+	if name, ok := segmentationNames[t]; ok {
+		return name
+	}
+	return "Reserved"
+}
+
+// hasSubSegments reports whether the sub_segment fields may follow
+// segments_expected for the segmentation type.
+func (t SegmentationType) hasSubSegments() bool {
+	switch t {
+	case SegmentationProviderPOStart, SegmentationDistributorPOStart,
+		SegmentationProviderOverlayPOStart, SegmentationDistributorOverlayPOStart,
+		SegmentationProviderAdBlockStart, SegmentationDistributorAdBlockStart:
+		return true
+	}
+	return false
+}
+
+// UPIDString returns the segmentation UPID in a printable form:
+// the characters for textual UPID types and hex digits otherwise.
+func (d *SegmentationDescriptor) UPIDString() string {
+	if d.UPIDType.textual() {
+		return string(d.UPID)
+	}
+	return hex.EncodeToString(d.UPID)
+}
+
+// SegmentationDescriptors returns the segmentation descriptors of the section.
+func (s *SpliceInfoSection) SegmentationDescriptors() []*SegmentationDescriptor {
+	var out []*SegmentationDescriptor
+	for _, d := range s.Descriptors {
+		if d.Segmentation != nil {
+			out = append(out, d.Segmentation)
+		}
+	}
+	return out
+}
+
+// Duration returns the duration of the break signalled by the section:
+// the break_duration of a splice_insert or the first segmentation_duration.
+// It returns zero if there is no duration.
+func (s *SpliceInfoSection) Duration() time.Duration {
+	if s.SpliceInsert != nil && s.SpliceInsert.BreakDuration != nil {
+		return TicksToDuration(s.SpliceInsert.BreakDuration.Duration)
+	}
+	for _, d := range s.SegmentationDescriptors() {
+		if d.HasDuration {
+			return TicksToDuration(d.Duration)
+		}
+	}
+	return 0
+}
+
+// TicksToDuration converts 90kHz ticks to time.Duration. Whole seconds
+// are converted apart so that 40-bit durations do not overflow.
+func TicksToDuration(ticks uint64) time.Duration {
+	return time.Duration(ticks/TicksPerSecond)*time.Second +
+		time.Duration(ticks%TicksPerSecond)*time.Second/TicksPerSecond
+}
+
+// DurationToTicks converts time.Duration to 90kHz ticks.
+func DurationToTicks(d time.Duration) uint64 {
+	return uint64(d/time.Second)*TicksPerSecond + uint64(d%time.Second*TicksPerSecond/time.Second)
+}
+
+// DecodeString decodes a splice_info_section from the cue as found in
+// playlists: base64 or hex digits (optionally prefixed with 0x).
+func DecodeString(cue string) (*SpliceInfoSection, error) {
+	cue = strings.TrimSpace(cue)
+	if strings.HasPrefix(cue, "0x") || strings.HasPrefix(cue, "0X") {
+		b, err := hex.DecodeString(cue[2:])
+		if err != nil {
+			return nil, err
+		}
+		return Decode(b)
+	}
+	b, err := base64.StdEncoding.DecodeString(cue)
+	if err != nil {
+		var herr error
+		if b, herr = hex.DecodeString(cue); herr != nil {
+			return nil, err
+		}
+	}
+	return Decode(b)
+}
+
+// Base64 returns the encoded section in base64, the form used by most
+// SCTE-35 tags of HLS playlists.
+func (s *SpliceInfoSection) Base64() (string, error) {
+	b, err := s.Encode()
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
+
+// Hex returns the encoded section as 0x-prefixed hex digits,
+// the form used by the SCTE35-* attributes of EXT-X-DATERANGE.
+func (s *SpliceInfoSection) Hex() (string, error) {
+	b, err := s.Encode()
+	if err != nil {
+		return "", err
+	}
+	return "0x" + strings.ToUpper(hex.EncodeToString(b)), nil
+}
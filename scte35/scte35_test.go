@@ -0,0 +1,215 @@
+package scte35_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/ShevaXu/hls/scte35"
+)
+
+// Samples from section 14 of SCTE 35 2019.
+const (
+	sampleSpliceInsert = "/DAvAAAAAAAA///wFAVIAACPf+/+c2nALv4AUsz1AAAAAAAKAAhDVUVJAAABNWLbowo="
+	sampleTimeSignal   = "/DA0AAAAAAAA///wBQb+cr0AUAAeAhxDVUVJSAAAjn/PAAGlmbAICAAAAAAsoKGKNAIAmsnRfg=="
+	// from sample-playlists/media-playlist-with-oatcls-scte35.m3u8
+	sampleOATCLS = "/DAlAAAAAAAAAP/wFAUAAAABf+/+ANgNkv4AFJlwAAEBAQAA5xULLA=="
+)
+
+func TestDecodeSpliceInsert(t *testing.T) {
+	s, err := scte35.DecodeString(sampleSpliceInsert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.CommandType != scte35.CommandSpliceInsert || s.SpliceInsert == nil {
+		t.Fatalf("Expected splice_insert, got command type %#x", s.CommandType)
+	}
+	c := s.SpliceInsert
+	if c.EventID != 0x4800008F {
+		t.Errorf("Expected event id 0x4800008F, got %#x", c.EventID)
+	}
+	if !c.OutOfNetwork || !c.ProgramSplice || c.SpliceImmediate {
+		t.Errorf("Unexpected flags: %+v", c)
+	}
+	if !c.SpliceTime.TimeSpecified || c.SpliceTime.PTSTime != 0x07369C02E {
+		t.Errorf("Unexpected splice time: %+v", c.SpliceTime)
+	}
+	if c.BreakDuration == nil || !c.BreakDuration.AutoReturn || c.BreakDuration.Duration != 0x00052CCF5 {
+		t.Errorf("Unexpected break duration: %+v", c.BreakDuration)
+	}
+	if len(s.Descriptors) != 1 || s.Descriptors[0].Tag != scte35.AvailDescriptorTag ||
+		!bytes.Equal(s.Descriptors[0].Data, []byte{0, 0, 1, 0x35}) {
+		t.Errorf("Unexpected descriptors: %+v", s.Descriptors)
+	}
+	if d := s.Duration(); d.Round(time.Millisecond) != 60294*time.Millisecond {
+		t.Errorf("Unexpected duration: %v", d)
+	}
+}
+
+func TestDecodeTimeSignal(t *testing.T) {
+	s, err := scte35.DecodeString(sampleTimeSignal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.TimeSignal == nil || s.TimeSignal.PTSTime != 0x072BD0050 {
+		t.Fatalf("Unexpected time_signal: %+v", s.TimeSignal)
+	}
+	segs := s.SegmentationDescriptors()
+	if len(segs) != 1 {
+		t.Fatalf("Expected 1 segmentation descriptor, got %d", len(segs))
+	}
+	d := segs[0]
+	if d.TypeID != scte35.SegmentationProviderPOStart {
+		t.Errorf("Expected %v, got %v", scte35.SegmentationProviderPOStart, d.TypeID)
+	}
+	if d.UPIDType != scte35.UPIDTI || d.UPIDString() != "000000002ca0a18a" {
+		t.Errorf("Unexpected UPID %v %s", d.UPIDType, d.UPIDString())
+	}
+	if !d.HasDuration || d.Duration != 0x0001A599B0 {
+		t.Errorf("Unexpected segmentation duration %v", d.Duration)
+	}
+	if s.Duration() != 307*time.Second {
+		t.Errorf("Expected 307s, got %v", s.Duration())
+	}
+	if d.SegmentNum != 2 || d.SegmentsExpected != 0 || d.HasSubSegments {
+		t.Errorf("Unexpected segment numbers: %+v", d)
+	}
+}
+
+func TestEncodeRoundTrip(t *testing.T) {
+	for _, cue := range []string{sampleSpliceInsert, sampleTimeSignal, sampleOATCLS} {
+		s, err := scte35.DecodeString(cue)
+		if err != nil {
+			t.Fatalf("%s: %s", cue, err)
+		}
+		out, err := s.Base64()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out != cue {
+			t.Errorf("Round trip mismatch\ngot: %s\nexp: %s", out, cue)
+		}
+	}
+}
+
+func TestDecodeHex(t *testing.T) {
+	s, err := scte35.DecodeString(sampleTimeSignal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := s.Hex()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = scte35.DecodeString(h); err != nil {
+		t.Errorf("Decode %s: %s", h, err)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	b, _ := base64.StdEncoding.DecodeString(sampleSpliceInsert)
+	b[20] ^= 0xFF
+	if _, err := scte35.Decode(b); err != scte35.ErrCRC {
+		t.Errorf("Expected %v, got %v", scte35.ErrCRC, err)
+	}
+	if _, err := scte35.Decode([]byte{0xFC, 0x30}); err != scte35.ErrShortSection {
+		t.Errorf("Expected %v, got %v", scte35.ErrShortSection, err)
+	}
+	if _, err := scte35.Decode([]byte{0x00, 0x30, 0x00}); err != scte35.ErrTableID {
+		t.Errorf("Expected %v, got %v", scte35.ErrTableID, err)
+	}
+}
+
+func TestEncodeSegmentationDescriptor(t *testing.T) {
+	s := &scte35.SpliceInfoSection{
+		SAPType:     3,
+		CWIndex:     0xFF,
+		Tier:        0xFFF,
+		CommandType: scte35.CommandTimeSignal,
+		TimeSignal:  &scte35.SpliceTime{TimeSpecified: true, PTSTime: 900000},
+		Descriptors: []*scte35.SpliceDescriptor{{
+			Tag:        scte35.SegmentationDescriptorTag,
+			Identifier: scte35.CUEIdentifier,
+			Segmentation: &scte35.SegmentationDescriptor{
+				EventID:               1,
+				ProgramSegmentation:   true,
+				HasDuration:           true,
+				DeliveryNotRestricted: true,
+				Duration:              scte35.DurationToTicks(30 * time.Second),
+				UPIDType:              scte35.UPIDAdID,
+				UPID:                  []byte("ABCD0123456H"),
+				TypeID:                scte35.SegmentationDistributorPOStart,
+				SegmentNum:            1,
+				SegmentsExpected:      1,
+				HasSubSegments:        true,
+				SubSegmentNum:         1,
+				SubSegmentsExpected:   2,
+			},
+		}},
+	}
+	b, err := s.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := scte35.Decode(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := got.SegmentationDescriptors()[0]
+	if d.UPIDString() != "ABCD0123456H" || !d.HasSubSegments || d.SubSegmentsExpected != 2 {
+		t.Errorf("Unexpected descriptor: %+v", d)
+	}
+	if got.Duration() != 30*time.Second {
+		t.Errorf("Expected 30s, got %v", got.Duration())
+	}
+}
+
+func TestAdBlockSubSegments(t *testing.T) {
+	for _, typeID := range []scte35.SegmentationType{scte35.SegmentationProviderAdBlockStart, scte35.SegmentationDistributorAdBlockStart} {
+		s := &scte35.SpliceInfoSection{
+			SAPType:     3,
+			Tier:        0xFFF,
+			CommandType: scte35.CommandTimeSignal,
+			TimeSignal:  &scte35.SpliceTime{TimeSpecified: true, PTSTime: 900000},
+			Descriptors: []*scte35.SpliceDescriptor{{
+				Tag:        scte35.SegmentationDescriptorTag,
+				Identifier: scte35.CUEIdentifier,
+				Segmentation: &scte35.SegmentationDescriptor{
+					EventID:             2,
+					ProgramSegmentation: true,
+					TypeID:              typeID,
+					HasSubSegments:      true,
+					SubSegmentNum:       2,
+					SubSegmentsExpected: 3,
+				},
+			}},
+		}
+		b, err := s.Encode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := scte35.Decode(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d := got.SegmentationDescriptors()[0]; !d.HasSubSegments || d.SubSegmentNum != 2 || d.SubSegmentsExpected != 3 {
+			t.Errorf("%v: unexpected descriptor %+v", typeID, d)
+		}
+	}
+}
+
+func TestTicksToDuration(t *testing.T) {
+	// the largest 40-bit segmentation_duration is about 3393 hours
+	const ticks = 1<<40 - 1
+	d := scte35.TicksToDuration(ticks)
+	if want := time.Duration(float64(ticks) / scte35.TicksPerSecond * float64(time.Second)); d-want > time.Microsecond || want-d > time.Microsecond {
+		t.Errorf("Expected %v, got %v", want, d)
+	}
+	if got := scte35.DurationToTicks(30 * time.Hour); got != 30*3600*scte35.TicksPerSecond {
+		t.Errorf("Expected %d ticks, got %d", 30*3600*scte35.TicksPerSecond, got)
+	}
+	if d := scte35.TicksToDuration(135000); d != 1500*time.Millisecond {
+		t.Errorf("Expected 1.5s, got %v", d)
+	}
+}
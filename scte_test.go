@@ -0,0 +1,223 @@
+package hls_test
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ShevaXu/hls"
+)
+
+func TestSCTEBreakDuration(t *testing.T) {
+	tests := []struct {
+		SCTE     *hls.SCTE
+		Expected float64
+	}{
+		{&hls.SCTE{Syntax: hls.SyntaxOATCLS, Time: 15}, 15},
+		{&hls.SCTE{Syntax: hls.SyntaxAdobe, Time: 30, Position: 414.171}, 30},
+		{&hls.SCTE{Syntax: hls.Syntax672014, Time: 123.12, Cue: "/DAlAAAAAAAAAP/wFAUAAAABf+/+ANgNkv4AFJlwAAEBAQAA5xULLA=="}, 15},
+		{&hls.SCTE{Syntax: hls.Syntax672014, Cue: "garbage"}, 0},
+	}
+	for _, test := range tests {
+		if d := test.SCTE.BreakDuration(); d != test.Expected {
+			t.Errorf("%+v: expected %v, got %v", test.SCTE, test.Expected, d)
+		}
+	}
+}
+
+func TestConvertSCTE35(t *testing.T) {
+	f, err := os.Open("sample-playlists/media-playlist-with-oatcls-scte35.m3u8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	p, _, err := hls.DecodeFrom(bufio.NewReader(f), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pp := p.(*hls.MediaPlaylist)
+	if err = pp.ConvertSCTE35(hls.SyntaxOATCLS, hls.SyntaxElemental, false); err != nil {
+		t.Fatal(err)
+	}
+	expected := "#EXT-X-CUE-OUT:DURATION=15\n#EXTINF:8.844,\nmedia0.ts\n" +
+		"#EXT-X-CUE-OUT-CONT:8.844/15\n#EXTINF:6.156,\nmedia1.ts\n" +
+		"#EXT-X-CUE-IN\n#EXTINF:3.844,\nmedia2.ts\n"
+	if !strings.HasSuffix(pp.String(), expected) {
+		t.Errorf("Expected suffix:\n%s\ngot:\n%s", expected, pp)
+	}
+
+	if err = pp.ConvertSCTE35(hls.SyntaxElemental, hls.Syntax672014, false); err != nil {
+		t.Fatal(err)
+	}
+	if pp.Segments[0].SCTE == nil || pp.Segments[1].SCTE != nil || pp.Segments[2].SCTE != nil {
+		t.Errorf("Expected only the start cue, got %+v %+v %+v", pp.Segments[0].SCTE, pp.Segments[1].SCTE, pp.Segments[2].SCTE)
+	}
+}
+
+// Only the cue out is given, cue out continuations and the cue in
+// are synthesised from the break duration of the binary cue.
+func TestConvertSCTE35Synthesise(t *testing.T) {
+	const cue = "/DAlAAAAAAAAAP/wFAUAAAABf+/+ANgNkv4AFJlwAAEBAQAA5xULLA=="
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	p, _ := hls.NewMediaPlaylist(0, 4)
+	p.Append(hls.QuickSegment("media0.ts", "", 8.844))
+	p.SetProgramDateTime(start)
+	p.SetSCTE35(&hls.SCTE{Syntax: hls.SyntaxAdobeSplicePoint, Cue: cue})
+	p.Append(hls.QuickSegment("media1.ts", "", 6.156))
+	p.Append(hls.QuickSegment("media2.ts", "", 3.844))
+	p.Append(hls.QuickSegment("media3.ts", "", 10))
+	if err := p.ConvertSCTE35(hls.SyntaxAdobeSplicePoint, hls.SyntaxOATCLS, true); err != nil {
+		t.Fatal(err)
+	}
+	expect := []*hls.SCTE{
+		{Syntax: hls.SyntaxOATCLS, CueType: hls.SCTE35CueStart, Cue: cue, Time: 15},
+		{Syntax: hls.SyntaxOATCLS, CueType: hls.SCTE35CueMid, Cue: cue, Time: 15, Elapsed: 8.844},
+		{Syntax: hls.SyntaxOATCLS, CueType: hls.SCTE35CueEnd},
+		nil,
+	}
+	for i, exp := range expect {
+		if !reflect.DeepEqual(p.Segments[i].SCTE, exp) {
+			t.Errorf("Segment %d\ngot: %#v\nexp: %#v", i, p.Segments[i].SCTE, exp)
+		}
+	}
+	out := p.String()
+	for _, exp := range []string{
+		`#EXT-X-DATERANGE:ID="splice-0",START-DATE="2020-01-01T00:00:00Z",PLANNED-DURATION=15,SCTE35-OUT=0xFC302500000000000000FFF01405000000017FEFFE00D80D92FE00149970000101010000E7150B2C` + "\n",
+		`#EXT-X-DATERANGE:ID="splice-0",START-DATE="2020-01-01T00:00:00Z",DURATION=15` + "\n#EXTINF:3.844,\n",
+	} {
+		if !strings.Contains(out, exp) {
+			t.Errorf("Expected %q in:\n%s", exp, out)
+		}
+	}
+
+	p.Segments[0].ProgramDateTime = time.Time{}
+	p.Segments[0].SCTE = &hls.SCTE{Syntax: hls.Syntax672014, Cue: cue}
+	if err := p.ConvertSCTE35(hls.Syntax672014, hls.SyntaxOATCLS, true); err == nil {
+		t.Error("Expected error for EXT-X-DATERANGE without EXT-X-PROGRAM-DATE-TIME")
+	}
+}
+
+func TestAdBreakForSlidingPlaylist(t *testing.T) {
+	p, _ := hls.NewMediaPlaylist(3, 3)
+	if err := p.StartAdBreak(hls.SyntaxOATCLS, 10, "CueData", false); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.StartAdBreak(hls.SyntaxOATCLS, 10, "CueData", false); err == nil {
+		t.Error("Expected error for ad break in progress")
+	}
+	var segs []*hls.MediaSegment
+	for i := 0; i < 5; i++ {
+		seg := hls.QuickSegment(fmt.Sprintf("test%d.ts", i), "", 4)
+		segs = append(segs, seg)
+		if _, err := p.Slide(seg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expect := []*hls.SCTE{
+		{Syntax: hls.SyntaxOATCLS, CueType: hls.SCTE35CueStart, Cue: "CueData", Time: 10},
+		{Syntax: hls.SyntaxOATCLS, CueType: hls.SCTE35CueMid, Cue: "CueData", Time: 10, Elapsed: 4},
+		{Syntax: hls.SyntaxOATCLS, CueType: hls.SCTE35CueMid, Cue: "CueData", Time: 10, Elapsed: 8},
+		{Syntax: hls.SyntaxOATCLS, CueType: hls.SCTE35CueEnd},
+		nil,
+	}
+	for i, exp := range expect {
+		if !reflect.DeepEqual(segs[i].SCTE, exp) {
+			t.Errorf("Segment %d\ngot: %#v\nexp: %#v", i, segs[i].SCTE, exp)
+		}
+	}
+	if p.InAdBreak() {
+		t.Error("Expected the ad break to be expired")
+	}
+	expected := "#EXT-X-CUE-OUT-CONT:ElapsedTime=8,Duration=10,SCTE35=CueData\n#EXTINF:4.000,\ntest2.ts\n#EXT-X-CUE-IN\n"
+	if !strings.Contains(p.String(), expected) {
+		t.Errorf("Expected %q in:\n%s", expected, p)
+	}
+}
+
+func TestAdBreakPreCuedSegments(t *testing.T) {
+	p, _ := hls.NewMediaPlaylist(0, 5)
+	p.StartAdBreak(hls.SyntaxOATCLS, 12, "CueData", false)
+	own := &hls.SCTE{Syntax: hls.SyntaxOATCLS, CueType: hls.SCTE35CueMid, Cue: "Other", Time: 30, Elapsed: 20}
+	for i := 0; i < 4; i++ {
+		seg := hls.QuickSegment(fmt.Sprintf("test%d.ts", i), "", 4)
+		if i == 1 {
+			seg.SCTE = own
+		}
+		p.Append(seg)
+	}
+	segs := p.Segments[:p.Count()]
+	if segs[1].SCTE != own {
+		t.Errorf("Expected the cue of the segment to be kept, got %#v", segs[1].SCTE)
+	}
+	// the pre-cued segment counts in the elapsed time and the duration
+	if s := segs[2].SCTE; s == nil || s.CueType != hls.SCTE35CueMid || s.Elapsed != 8 {
+		t.Errorf("Expected a cue out continuation at 8s, got %#v", s)
+	}
+	if s := segs[3].SCTE; s == nil || s.CueType != hls.SCTE35CueEnd || p.InAdBreak() {
+		t.Errorf("Expected the break to expire, got %#v", s)
+	}
+}
+
+func TestEndAdBreak(t *testing.T) {
+	p, _ := hls.NewMediaPlaylist(0, 4)
+	p.StartAdBreak(hls.SyntaxOATCLS, 30, "CueData", false)
+	p.Append(hls.QuickSegment("test0.ts", "", 4))
+	p.EndAdBreak()
+	if !p.InAdBreak() {
+		t.Error("Expected the ad break to end with the next segment")
+	}
+	p.Append(hls.QuickSegment("test1.ts", "", 4))
+	if p.InAdBreak() {
+		t.Error("Expected the ad break to be ended")
+	}
+	if s := p.Segments[1].SCTE; s == nil || s.CueType != hls.SCTE35CueEnd {
+		t.Errorf("Expected cue in, got %#v", s)
+	}
+	p.StartAdBreak(hls.SyntaxOATCLS, 30, "CueData", false)
+	p.EndAdBreak()
+	p.Append(hls.QuickSegment("test2.ts", "", 4))
+	if p.Segments[2].SCTE != nil {
+		t.Errorf("Expected no cue for a cancelled ad break, got %#v", p.Segments[2].SCTE)
+	}
+}
+
+func TestAdBreakAdobeDateRange(t *testing.T) {
+	p, _ := hls.NewMediaPlaylist(0, 5)
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	first := hls.QuickSegment("test0.ts", "", 4)
+	first.ProgramDateTime = start
+	p.Append(first)
+	if err := p.StartAdBreak(hls.SyntaxAdobe, 8, "/DAlAAAAAAAAAP/wFAUAAAABf+/+ANgNkv4AFJlwAAEBAQAA5xULLA==", true); err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i < 5; i++ {
+		p.Append(hls.QuickSegment(fmt.Sprintf("test%d.ts", i), "", 4))
+	}
+	segs := p.Segments[:p.Count()]
+	if s := segs[1].SCTE; s == nil || s.Syntax != hls.SyntaxAdobe || s.CueType != hls.SCTE35CueStart || s.Time != 8 {
+		t.Errorf("Unexpected cue out %#v", s)
+	}
+	if segs[2].SCTE != nil {
+		t.Errorf("Expected no cue inside the break in Adobe syntax, got %#v", segs[2].SCTE)
+	}
+	if s := segs[3].SCTE; s == nil || s.Syntax != hls.SyntaxAdobe || s.CueType != hls.SCTE35CueEnd {
+		t.Errorf("Unexpected cue in %#v", s)
+	}
+	if len(segs[1].DateRanges) != 1 || !segs[1].DateRanges[0].StartDate.Equal(start.Add(4*time.Second)) ||
+		segs[1].DateRanges[0].PlannedDuration != 8 || segs[1].DateRanges[0].SCTE35Out == "" {
+		t.Errorf("Unexpected cue out date range %+v", segs[1].DateRanges)
+	}
+	if len(segs[3].DateRanges) != 1 || segs[3].DateRanges[0].ID != segs[1].DateRanges[0].ID || segs[3].DateRanges[0].Duration != 8 {
+		t.Errorf("Unexpected cue in date range %+v", segs[3].DateRanges)
+	}
+	out := p.String()
+	for _, tag := range []string{`#EXT-X-CUE:TYPE="SpliceOut",DURATION=8,CUE=`, `#EXT-X-CUE:TYPE="SpliceIn"`, "SCTE35-OUT=0x", `ID="splice-1"`} {
+		if !strings.Contains(out, tag) {
+			t.Errorf("Expected %q in:\n%s", tag, out)
+		}
+	}
+}
@@ -0,0 +1,264 @@
+// Package sign signs playlist URIs with expiring HMAC-SHA256 tokens and
+// verifies them at the origin.
+//
+// A Signer is a hls.URIRewriter, so the URIs are signed while a playlist is
+// encoded, for example per request in a handler:
+//
+//	s := &sign.Signer{Format: sign.Akamai, Key: key, TTL: time.Hour, Base: playlistURL}
+//	w.Write(p.EncodeWith(s).Bytes())
+//
+// and the origin serving the segments checks them with a Verifier:
+//
+//	http.Handle("/live/", (&sign.Verifier{Format: sign.Akamai, Key: key}).Handler(files))
+//
+// The tokens follow the layout of the Akamai, CloudFront and Wowza query
+// parameters, all signed with HMAC-SHA256 over the path of the URI and the
+// expiry time. The rest of the query is not signed.
+package sign
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ShevaXu/hls"
+)
+
+// Format is the layout of the token in the query.
+type Format int
+
+// Token formats.
+const (
+	// Akamai appends hdnts=exp=<unix>~acl=<path>~hmac=<hex>, query escaped.
+	Akamai Format = iota
+	// CloudFront appends Expires=<unix>&Signature=<base64url>&Key-Pair-Id=<id>.
+	CloudFront
+	// Wowza appends wowzatokenendtime=<unix>&wowzatokenhash=<base64url>.
+	Wowza
+)
+
+// Query parameters of the tokens.
+const (
+	AkamaiParam         = "hdnts"
+	CloudFrontExpires   = "Expires"
+	CloudFrontSignature = "Signature"
+	CloudFrontKeyPairID = "Key-Pair-Id"
+	WowzaEndTimeParam   = "wowzatokenendtime"
+	WowzaHashParam      = "wowzatokenhash"
+)
+
+// defaultTTL is the validity of the tokens if Signer.TTL is zero.
+const defaultTTL = time.Hour
+
+// Errors returned by Verifier.Verify.
+var (
+	ErrNoToken   = errors.New("sign: missing token")
+	ErrExpired   = errors.New("sign: token expired")
+	ErrSignature = errors.New("sign: invalid signature")
+)
+
+// Signer signs URIs, it implements hls.URIRewriter.
+type Signer struct {
+	Format Format
+	Key    []byte
+	KeyID  string        // Key-Pair-Id of CloudFront tokens
+	TTL    time.Duration // validity of the tokens, one hour if zero
+	// Base is the URL of the encoded playlist, relative URIs are resolved
+	// against it to sign the path the origin will see.
+	Base *url.URL
+	// ACL, if set, is signed instead of the path in Akamai tokens.
+	// A trailing * matches any path with the prefix, e.g. "/live/*".
+	ACL string
+	// Kinds are the kinds of URIs to sign, segments, keys and maps if empty.
+	Kinds []hls.URIKind
+	// Now returns the current time, time.Now if nil.
+	Now func() time.Time
+}
+
+// RewriteURI signs the URI if its kind is one of Kinds.
+// It returns the URI as is if it can not be parsed.
+func (s *Signer) RewriteURI(kind hls.URIKind, uri string) string {
+	if !s.signs(kind) {
+		return uri
+	}
+	ttl := s.TTL
+	if ttl == 0 {
+		ttl = defaultTTL
+	}
+	signed, err := s.Sign(uri, now(s.Now).Add(ttl))
+	if err != nil {
+		return uri
+	}
+	return signed
+}
+
+func (s *Signer) signs(kind hls.URIKind) bool {
+	if len(s.Kinds) == 0 {
+		return kind == hls.URISegment || kind == hls.URIKey || kind == hls.URIMap
+	}
+	for _, k := range s.Kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// Sign appends a token valid until expires to the URI.
+func (s *Signer) Sign(uri string, expires time.Time) (string, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", err
+	}
+	resource := u
+	if s.Base != nil {
+		resource = s.Base.ResolveReference(u)
+	}
+	exp := strconv.FormatInt(expires.Unix(), 10)
+	var token string
+	switch s.Format {
+	case Akamai:
+		acl := s.ACL
+		if acl == "" {
+			acl = resource.EscapedPath()
+		}
+		fields := "exp=" + exp + "~acl=" + acl
+		// the escaped path may hold % and + which the query would decode
+		token = AkamaiParam + "=" + url.QueryEscape(fields+"~hmac="+hex.EncodeToString(mac(s.Key, fields)))
+	case CloudFront:
+		sig := base64.RawURLEncoding.EncodeToString(mac(s.Key, resource.EscapedPath()+"?"+CloudFrontExpires+"="+exp))
+		token = CloudFrontExpires + "=" + exp + "&" + CloudFrontSignature + "=" + sig
+		if s.KeyID != "" {
+			token += "&" + CloudFrontKeyPairID + "=" + url.QueryEscape(s.KeyID)
+		}
+	case Wowza:
+		sig := base64.RawURLEncoding.EncodeToString(mac(s.Key, resource.EscapedPath()+"?"+WowzaEndTimeParam+"="+exp))
+		token = WowzaEndTimeParam + "=" + exp + "&" + WowzaHashParam + "=" + sig
+	default:
+		return "", errors.New("sign: unknown format")
+	}
+	return hls.AppendQuery(uri, token), nil
+}
+
+// Verifier checks the tokens of requested URLs.
+type Verifier struct {
+	Format Format
+	Key    []byte
+	KeyID  string // expected Key-Pair-Id of CloudFront tokens, not checked if empty
+	// Now returns the current time, time.Now if nil.
+	Now func() time.Time
+}
+
+// Verify checks the token in the query of the URL against its path.
+func (v *Verifier) Verify(u *url.URL) error {
+	q := u.Query()
+	path := u.EscapedPath()
+	var (
+		exp      string
+		expected []byte
+		got      []byte
+		err      error
+	)
+	switch v.Format {
+	case Akamai:
+		token := q.Get(AkamaiParam)
+		if token == "" {
+			return ErrNoToken
+		}
+		i := strings.LastIndex(token, "~hmac=")
+		if i < 0 {
+			return ErrSignature
+		}
+		fields := token[:i]
+		var acl string
+		for _, field := range strings.Split(fields, "~") {
+			switch {
+			case strings.HasPrefix(field, "exp="):
+				exp = field[4:]
+			case strings.HasPrefix(field, "acl="):
+				acl = field[4:]
+			}
+		}
+		if !matchACL(acl, path) {
+			return ErrSignature
+		}
+		if got, err = hex.DecodeString(token[i+6:]); err != nil {
+			return ErrSignature
+		}
+		expected = mac(v.Key, fields)
+	case CloudFront:
+		exp = q.Get(CloudFrontExpires)
+		if exp == "" || q.Get(CloudFrontSignature) == "" {
+			return ErrNoToken
+		}
+		if v.KeyID != "" && q.Get(CloudFrontKeyPairID) != v.KeyID {
+			return ErrSignature
+		}
+		if got, err = base64.RawURLEncoding.DecodeString(q.Get(CloudFrontSignature)); err != nil {
+			return ErrSignature
+		}
+		expected = mac(v.Key, path+"?"+CloudFrontExpires+"="+exp)
+	case Wowza:
+		exp = q.Get(WowzaEndTimeParam)
+		if exp == "" || q.Get(WowzaHashParam) == "" {
+			return ErrNoToken
+		}
+		if got, err = base64.RawURLEncoding.DecodeString(q.Get(WowzaHashParam)); err != nil {
+			return ErrSignature
+		}
+		expected = mac(v.Key, path+"?"+WowzaEndTimeParam+"="+exp)
+	default:
+		return errors.New("sign: unknown format")
+	}
+	if !hmac.Equal(got, expected) {
+		return ErrSignature
+	}
+	expires, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil {
+		return ErrSignature
+	}
+	if now(v.Now).Unix() > expires {
+		return ErrExpired
+	}
+	return nil
+}
+
+// Handler returns a handler which serves the requests with a valid token
+// with next and rejects the others with 403 Forbidden.
+func (v *Verifier) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := v.Verify(r.URL); err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// matchACL reports whether the path is covered by the Akamai ACL.
+func matchACL(acl, path string) bool {
+	if strings.HasSuffix(acl, "*") {
+		return strings.HasPrefix(path, acl[:len(acl)-1])
+	}
+	return acl == path
+}
+
+func mac(key []byte, msg string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(msg))
+	return h.Sum(nil)
+}
+
+func now(fn func() time.Time) time.Time {
+	if fn == nil {
+		return time.Now()
+	}
+	return fn()
+}
@@ -0,0 +1,145 @@
+package sign_test
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ShevaXu/hls"
+	"github.com/ShevaXu/hls/sign"
+)
+
+var (
+	key     = []byte("secret")
+	signNow = time.Unix(1600000000, 0)
+)
+
+func clock(t time.Time) func() time.Time {
+	return func() time.Time { return t }
+}
+
+func TestSignAndVerify(t *testing.T) {
+	base, _ := url.Parse("https://cdn.example.com/live/hd/index.m3u8")
+	for _, format := range []sign.Format{sign.Akamai, sign.CloudFront, sign.Wowza} {
+		s := &sign.Signer{Format: format, Key: key, KeyID: "K1", TTL: time.Minute, Base: base, Now: clock(signNow)}
+		signed := s.RewriteURI(hls.URISegment, "seg0.ts?v=1")
+		if !strings.HasPrefix(signed, "seg0.ts?v=1&") {
+			t.Errorf("Format %d: unexpected signed URI %s", format, signed)
+		}
+		u, err := base.Parse(signed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		v := &sign.Verifier{Format: format, Key: key, KeyID: "K1", Now: clock(signNow.Add(30 * time.Second))}
+		if err = v.Verify(u); err != nil {
+			t.Errorf("Format %d: %s for %s", format, err, u)
+		}
+		v.Now = clock(signNow.Add(2 * time.Minute))
+		if err = v.Verify(u); err != sign.ErrExpired {
+			t.Errorf("Format %d: expected %v, got %v", format, sign.ErrExpired, err)
+		}
+		v.Now = nil
+		other, _ := base.Parse(strings.Replace(signed, "seg0", "seg1", 1))
+		if err = v.Verify(other); err != sign.ErrSignature {
+			t.Errorf("Format %d: expected %v for another path, got %v", format, sign.ErrSignature, err)
+		}
+		v.Key = []byte("other")
+		if err = v.Verify(u); err != sign.ErrSignature {
+			t.Errorf("Format %d: expected %v for another key, got %v", format, sign.ErrSignature, err)
+		}
+		if err = v.Verify(base); err != sign.ErrNoToken {
+			t.Errorf("Format %d: expected %v, got %v", format, sign.ErrNoToken, err)
+		}
+		if uri := s.RewriteURI(hls.URIVariant, "hd/index.m3u8"); uri != "hd/index.m3u8" {
+			t.Errorf("Format %d: expected variant URI left as is, got %s", format, uri)
+		}
+	}
+}
+
+func TestAkamaiACL(t *testing.T) {
+	s := &sign.Signer{Format: sign.Akamai, Key: key, ACL: "/live/*"}
+	signed, err := s.Sign("/live/hd/seg0.ts", time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := &sign.Verifier{Format: sign.Akamai, Key: key}
+	token := signed[strings.Index(signed, "?"):]
+	for _, path := range []string{"/live/hd/seg0.ts", "/live/sd/seg9.ts"} {
+		u, _ := url.Parse(path + token)
+		if err = v.Verify(u); err != nil {
+			t.Errorf("%s: %s", path, err)
+		}
+	}
+	u, _ := url.Parse("/vod/seg0.ts" + token)
+	if err = v.Verify(u); err != sign.ErrSignature {
+		t.Errorf("Expected %v outside the ACL, got %v", sign.ErrSignature, err)
+	}
+}
+
+func TestAkamaiEscapedPath(t *testing.T) {
+	s := &sign.Signer{Format: sign.Akamai, Key: key}
+	v := &sign.Verifier{Format: sign.Akamai, Key: key}
+	for _, uri := range []string{"/live/my%20show/seg0.ts", "/live/a+b/seg0.ts"} {
+		signed, err := s.Sign(uri, time.Now().Add(time.Minute))
+		if err != nil {
+			t.Fatal(err)
+		}
+		u, _ := url.Parse(signed)
+		if err = v.Verify(u); err != nil {
+			t.Errorf("%s: %s", signed, err)
+		}
+	}
+}
+
+func TestSignedPlaylistServedByVerifier(t *testing.T) {
+	p, err := hls.NewMediaPlaylist(3, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Append(hls.QuickSegment("seg0.ts", "", 4))
+	p.SetKey("AES-128", "/keys/0.key", "", "", "")
+	p.Append(hls.QuickSegment("seg1.ts", "", 4))
+
+	files := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("ok")) })
+	srv := httptest.NewServer((&sign.Verifier{Format: sign.CloudFront, Key: key}).Handler(files))
+	defer srv.Close()
+	base, _ := url.Parse(srv.URL + "/live/index.m3u8")
+	out := p.EncodeWith(&sign.Signer{Format: sign.CloudFront, Key: key, Base: base})
+
+	var uris []string
+	sc := bufio.NewScanner(out)
+	for sc.Scan() {
+		line := sc.Text()
+		if i := strings.Index(line, `URI="`); i >= 0 {
+			uris = append(uris, strings.SplitN(line[i+5:], `"`, 2)[0])
+		} else if line != "" && !strings.HasPrefix(line, "#") {
+			uris = append(uris, line)
+		}
+	}
+	if len(uris) != 3 {
+		t.Fatalf("Expected 3 URIs, got %v", uris)
+	}
+	for _, uri := range uris {
+		u, _ := base.Parse(uri)
+		resp, err := http.Get(u.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("%s: %s", uri, resp.Status)
+		}
+	}
+	resp, err := http.Get(srv.URL + "/live/seg0.ts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected 403 without token, got %s", resp.Status)
+	}
+}
@@ -0,0 +1,130 @@
+package hls
+
+import "time"
+
+// Stats are the duration and timeline statistics of a media playlist,
+// see MediaPlaylist.Stats.
+type Stats struct {
+	Segments        int
+	Duration        time.Duration // sum of the EXTINF durations
+	MinDuration     time.Duration
+	MaxDuration     time.Duration
+	MeanDuration    time.Duration
+	Discontinuities int       // EXT-X-DISCONTINUITY tags
+	Start, End      time.Time // wall-clock span, zero without EXT-X-PROGRAM-DATE-TIME
+	Timelines       []Timeline
+	Drifts          []Drift       // program date times disagreeing with the durations
+	MaxDrift        time.Duration // largest absolute drift
+}
+
+// Timeline describes the segments sharing a discontinuity sequence number.
+type Timeline struct {
+	DiscontinuitySeq int
+	FirstSeqID       int // media sequence number of the first segment
+	Segments         int
+	Offset           time.Duration // start from the start of the playlist
+	Duration         time.Duration
+	Start, End       time.Time // wall-clock span, zero without EXT-X-PROGRAM-DATE-TIME
+}
+
+// Drift compares the EXTINF durations between two segments of a timeline
+// having EXT-X-PROGRAM-DATE-TIME with the difference of their dates.
+type Drift struct {
+	FromSeqID, ToSeqID int
+	Duration           time.Duration // sum of the EXTINF durations in between
+	Elapsed            time.Duration // difference of the program date times
+}
+
+// Drift returns how much the program date times run ahead of the durations.
+func (d Drift) Drift() time.Duration {
+	return d.Elapsed - d.Duration
+}
+
+// driftPrecision is the precision of EXT-X-PROGRAM-DATE-TIME below which
+// differences are not reported.
+const driftPrecision = time.Millisecond
+
+// Stats returns the duration and timeline statistics of the segments in
+// the playlist. Timelines are split at discontinuities and numbered from
+// DiscontinuitySeq, incremented by every EXT-X-DISCONTINUITY tag including
+// one on the first segment, the way Remove counts them; wall-clock times
+// are interpolated within a timeline only.
+func (p *MediaPlaylist) Stats() Stats {
+	var st Stats
+	segs := p.segments()
+	st.Segments = len(segs)
+	var tl *Timeline
+	from := 0 // first segment of the timeline
+	for i, seg := range segs {
+		d := seconds(seg.Duration)
+		if i == 0 || d < st.MinDuration {
+			st.MinDuration = d
+		}
+		if d > st.MaxDuration {
+			st.MaxDuration = d
+		}
+		if seg.Discontinuity {
+			st.Discontinuities++
+		}
+		if tl == nil || seg.Discontinuity {
+			if tl != nil {
+				st.timeline(tl, segs[from:i], p.SeqNo+from)
+			}
+			st.Timelines = append(st.Timelines, Timeline{
+				DiscontinuitySeq: p.DiscontinuitySeq + st.Discontinuities,
+				FirstSeqID:       p.SeqNo + i,
+				Offset:           st.Duration,
+			})
+			tl, from = &st.Timelines[len(st.Timelines)-1], i
+		}
+		tl.Segments++
+		tl.Duration += d
+		st.Duration += d
+	}
+	if tl != nil {
+		st.timeline(tl, segs[from:], p.SeqNo+from)
+		st.MeanDuration = st.Duration / time.Duration(len(segs))
+	}
+	for _, tl := range st.Timelines {
+		if tl.Start.IsZero() {
+			continue
+		}
+		if st.Start.IsZero() {
+			st.Start = tl.Start
+		}
+		st.End = tl.End
+	}
+	return st
+}
+
+// timeline sets the wall-clock span of the timeline of segs and adds the
+// drifts between its dated segments.
+func (st *Stats) timeline(tl *Timeline, segs []*MediaSegment, seqID int) {
+	times := programDateTimes(segs)
+	if times[0].IsZero() {
+		return
+	}
+	tl.Start = times[0]
+	tl.End = times[len(segs)-1].Add(seconds(segs[len(segs)-1].Duration))
+	prev := -1
+	var sum time.Duration
+	for i, seg := range segs {
+		if !seg.ProgramDateTime.IsZero() {
+			if prev >= 0 {
+				d := Drift{FromSeqID: seqID + prev, ToSeqID: seqID + i, Duration: sum, Elapsed: seg.ProgramDateTime.Sub(segs[prev].ProgramDateTime)}
+				abs := d.Drift()
+				if abs < 0 {
+					abs = -abs
+				}
+				if abs >= driftPrecision {
+					st.Drifts = append(st.Drifts, d)
+				}
+				if abs > st.MaxDrift {
+					st.MaxDrift = abs
+				}
+			}
+			prev, sum = i, 0
+		}
+		sum += seconds(seg.Duration)
+	}
+}
@@ -0,0 +1,95 @@
+package hls_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ShevaXu/hls"
+)
+
+func TestStats(t *testing.T) {
+	playlist := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-MEDIA-SEQUENCE:10
+#EXT-X-DISCONTINUITY-SEQUENCE:2
+#EXT-X-TARGETDURATION:6
+#EXT-X-PROGRAM-DATE-TIME:2020-01-01T00:00:00Z
+#EXTINF:6.000,
+a.ts
+#EXTINF:4.000,
+b.ts
+#EXT-X-PROGRAM-DATE-TIME:2020-01-01T00:00:10.500Z
+#EXTINF:5.000,
+c.ts
+#EXT-X-DISCONTINUITY
+#EXTINF:2.000,
+d.ts
+#EXT-X-ENDLIST
+`
+	p, _ := hls.NewMediaPlaylist(0, 10)
+	if err := p.DecodeFrom(bytes.NewBufferString(playlist), true); err != nil {
+		t.Fatal(err)
+	}
+	if p.DiscontinuitySeq != 2 {
+		t.Fatalf("Expected discontinuity sequence 2, got %d", p.DiscontinuitySeq)
+	}
+	st := p.Stats()
+	if st.Segments != 4 || st.Duration != 17*time.Second || st.MinDuration != 2*time.Second ||
+		st.MaxDuration != 6*time.Second || st.MeanDuration != 4250*time.Millisecond || st.Discontinuities != 1 {
+		t.Errorf("Unexpected durations %+v", st)
+	}
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	if !st.Start.Equal(start) || !st.End.Equal(start.Add(15500*time.Millisecond)) {
+		t.Errorf("Unexpected span %v - %v", st.Start, st.End)
+	}
+	if len(st.Timelines) != 2 {
+		t.Fatalf("Expected 2 timelines, got %d", len(st.Timelines))
+	}
+	if tl := st.Timelines[0]; tl.DiscontinuitySeq != 2 || tl.FirstSeqID != 10 || tl.Segments != 3 ||
+		tl.Duration != 15*time.Second || !tl.Start.Equal(start) {
+		t.Errorf("Unexpected first timeline %+v", tl)
+	}
+	// the second timeline has no date of its own
+	if tl := st.Timelines[1]; tl.DiscontinuitySeq != 3 || tl.FirstSeqID != 13 || tl.Offset != 15*time.Second || !tl.Start.IsZero() {
+		t.Errorf("Unexpected second timeline %+v", tl)
+	}
+	if len(st.Drifts) != 1 || st.Drifts[0].Drift() != 500*time.Millisecond || st.MaxDrift != 500*time.Millisecond {
+		t.Errorf("Unexpected drifts %+v", st.Drifts)
+	}
+	if d := st.Drifts[0]; d.FromSeqID != 10 || d.ToSeqID != 12 || d.Duration != 10*time.Second {
+		t.Errorf("Unexpected drift %+v", d)
+	}
+	if !strings.Contains(p.Encode().String(), "#EXT-X-DISCONTINUITY-SEQUENCE:2\n") {
+		t.Errorf("Expected EXT-X-DISCONTINUITY-SEQUENCE in\n%s", p)
+	}
+}
+
+func TestStatsEmpty(t *testing.T) {
+	p, _ := hls.NewMediaPlaylist(3, 5)
+	if st := p.Stats(); st.Segments != 0 || st.Timelines != nil || st.MeanDuration != 0 {
+		t.Errorf("Unexpected stats %+v", st)
+	}
+}
+
+func TestRemoveDiscontinuitySeq(t *testing.T) {
+	p, _ := hls.NewMediaPlaylist(2, 3)
+	p.Append(&hls.MediaSegment{URI: "a.ts", Duration: 4})
+	p.Append(&hls.MediaSegment{URI: "b.ts", Duration: 4, Discontinuity: true})
+	if st := p.Stats(); st.Timelines[1].DiscontinuitySeq != 1 {
+		t.Errorf("Expected timeline 1 of b.ts, got %+v", st.Timelines)
+	}
+	p.Remove()
+	if p.DiscontinuitySeq != 0 {
+		t.Errorf("Expected discontinuity sequence 0, got %d", p.DiscontinuitySeq)
+	}
+	// the timeline of b.ts keeps its number with its discontinuity first
+	if st := p.Stats(); len(st.Timelines) != 1 || st.Timelines[0].DiscontinuitySeq != 1 {
+		t.Errorf("Expected timeline 1 of b.ts, got %+v", st.Timelines)
+	}
+	p.Remove()
+	if p.DiscontinuitySeq != 1 {
+		t.Errorf("Expected discontinuity sequence 1, got %d", p.DiscontinuitySeq)
+	}
+}
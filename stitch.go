@@ -0,0 +1,350 @@
+package hls
+
+import (
+	"context"
+	"time"
+)
+
+// AdAvail describes an ad break signalled in the content, see Stitcher.
+type AdAvail struct {
+	ID              string
+	Cue             string  // SCTE-35 cue of the break, base64 or hex
+	Duration        float64 // planned duration in seconds, zero if unknown
+	SeqID           int     // media sequence number of the first segment of the break
+	ProgramDateTime time.Time
+}
+
+// AdDecider chooses the ads played in an ad break.
+type AdDecider interface {
+	// Decide returns the ad playlists to play in order in the break,
+	// none to keep the content. The playlists are not modified.
+	Decide(ctx context.Context, avail *AdAvail) ([]*MediaPlaylist, error)
+}
+
+// AdDeciderFunc is an adapter to use ordinary functions as AdDecider.
+type AdDeciderFunc func(ctx context.Context, avail *AdAvail) ([]*MediaPlaylist, error)
+
+// Decide calls f(ctx, avail).
+func (f AdDeciderFunc) Decide(ctx context.Context, avail *AdAvail) ([]*MediaPlaylist, error) {
+	return f(ctx, avail)
+}
+
+// stitchTolerance is how much longer in seconds than the break the ads
+// may be.
+const stitchTolerance = 0.5
+
+// Stitcher replaces the segments of the ad breaks of a content playlist
+// with ads (server-side ad insertion).
+//
+// A break starts on a segment with a SCTE-35 cue out or an EXT-X-DATERANGE
+// with SCTE35-OUT, and ends before the segment with the cue in, or the
+// EXT-X-DATERANGE with SCTE35-IN, or once its duration elapsed. Breaks
+// whose start was not seen are left as is.
+//
+// The ads chosen by the decider are trimmed to the planned duration of the
+// break and padded with the Slate segments if set, each ad playlist and
+// each pass of the slate starting with a discontinuity. An ad segment is
+// published once the content reached its end, the content following the
+// ads gets a discontinuity, and the content of the break left once the
+// ads are over is kept. When the break ends, the ads which did not start
+// yet are dropped.
+//
+// Stitch is called with every reload of a live playlist: the stitched
+// playlist slides along the content, keeping its media and discontinuity
+// sequence numbers consistent across reloads. Ad and slate URIs should be
+// absolute as they are copied as is.
+type Stitcher struct {
+	Decider AdDecider
+	Slate   *MediaPlaylist // optional filler of the breaks
+
+	out     *MediaPlaylist
+	anchors []int  // content media sequence number of every output segment, by position from the head
+	next    int    // media sequence number of the next content segment to process
+	keys    []*Key // keys in effect in the output
+	init    *Map   // map in effect in the output
+	brk     *stitchBreak
+}
+
+// stitchBreak is an ad break in progress.
+type stitchBreak struct {
+	avail   *AdAvail
+	ads     []*stitchItem
+	next    int     // next ad to publish
+	adsEnd  float64 // duration of the ads
+	elapsed float64 // duration of the content of the break so far
+	joined  bool    // the content of the break is played again
+}
+
+// stitchItem is an ad segment with the key and the map in effect for it.
+type stitchItem struct {
+	seg      *MediaSegment
+	keys     []*Key
+	init     *Map
+	from, to float64 // offsets in the break
+}
+
+// NewStitcher creates a stitcher of the ads chosen by the decider.
+func NewStitcher(decider AdDecider) *Stitcher {
+	return &Stitcher{Decider: decider}
+}
+
+// Stitch returns the content playlist with the ads of its breaks. The
+// returned playlist belongs to the stitcher and changes with the next
+// call. Segments of the content are copied, the content is not modified.
+// If the decider fails, the error is returned and the break is decided
+// again with the next call.
+func (s *Stitcher) Stitch(ctx context.Context, content *MediaPlaylist) (*MediaPlaylist, error) {
+	segs := content.segments()
+	if s.out == nil {
+		out, err := NewMediaPlaylist(0, len(segs)+1)
+		if err != nil {
+			return nil, err
+		}
+		out.SeqNo, out.DiscontinuitySeq = content.SeqNo, content.DiscontinuitySeq
+		s.out, s.next = out, content.SeqNo
+	}
+	s.slide(content.SeqNo)
+
+	out := s.out
+	checkVersion(&out.ver, content.ver)
+	if out.TargetDuration < content.TargetDuration {
+		out.TargetDuration = content.TargetDuration
+	}
+	keys, init := keySet(content.Key, content.Keys), content.Map
+	for i, seg := range segs {
+		if seg.Key != nil {
+			if keys = keySet(seg.Key, seg.Keys); seg.Key.Method == KeyMethodNone {
+				keys = nil
+			}
+		}
+		if seg.Map != nil {
+			init = seg.Map
+		}
+		seqID := content.SeqNo + i
+		if seqID < s.next {
+			continue
+		}
+		if err := s.stitch(ctx, seg, seqID, keys, init); err != nil {
+			out.ResetCache()
+			return out, err
+		}
+		s.next = seqID + 1
+	}
+	out.Closed, out.MediaType = content.Closed, content.MediaType
+	out.ResetCache()
+	return out, nil
+}
+
+// slide removes the output segments of the content segments before seqNo,
+// carrying their key and map over to the new first segment.
+func (s *Stitcher) slide(seqNo int) {
+	var keys []*Key
+	var init *Map
+	removed := false
+	for len(s.anchors) > 0 && s.anchors[0] < seqNo {
+		seg, err := s.out.Remove()
+		if err != nil {
+			break
+		}
+		if seg.Key != nil {
+			keys = keySet(seg.Key, seg.Keys)
+		}
+		if seg.Map != nil {
+			init = seg.Map
+		}
+		s.anchors = s.anchors[1:]
+		removed = true
+	}
+	if !removed || s.out.count == 0 {
+		return
+	}
+	head := s.out.Segments[s.out.head]
+	if head.Key == nil && keys != nil {
+		head.Key = keys[0]
+		if len(keys) > 1 {
+			head.Keys = keys
+		}
+	}
+	if head.Map == nil && init != nil {
+		head.Map = init
+	}
+}
+
+// stitch processes a new content segment. The state is left unchanged
+// if the decider fails.
+func (s *Stitcher) stitch(ctx context.Context, seg *MediaSegment, seqID int, keys []*Key, init *Map) error {
+	b, ended := s.brk, false
+	if b != nil && b.ends(seg) {
+		b, ended = nil, true
+	}
+	if b == nil {
+		var err error
+		if b, err = s.decide(ctx, seg, seqID); err != nil {
+			return err
+		}
+	}
+	if ended {
+		// the ads which started before the end of the break are kept
+		prev := s.brk
+		for ; prev.next < len(prev.ads) && prev.ads[prev.next].from < prev.elapsed-0.001; prev.next++ {
+			ad := prev.ads[prev.next]
+			s.push(ad.seg, seqID, ad.keys, ad.init)
+		}
+		ended = !prev.joined
+	}
+	s.brk = b
+	if b == nil {
+		cp := *seg
+		cp.Discontinuity = cp.Discontinuity || ended
+		s.push(&cp, seqID, keys, init)
+		return nil
+	}
+
+	from := b.elapsed
+	b.elapsed += seg.Duration
+	for ; b.next < len(b.ads) && b.ads[b.next].to <= b.elapsed+0.001; b.next++ {
+		ad := b.ads[b.next]
+		s.push(ad.seg, seqID, ad.keys, ad.init)
+	}
+	if from >= b.adsEnd-0.001 {
+		cp := *seg
+		if !b.joined {
+			cp.Discontinuity, b.joined = true, true
+		}
+		s.push(&cp, seqID, keys, init)
+	}
+	return nil
+}
+
+// decide returns the break signalled on the segment with its ads, or nil
+// if there is no break or no ads.
+func (s *Stitcher) decide(ctx context.Context, seg *MediaSegment, seqID int) (*stitchBreak, error) {
+	avail := availOf(seg, seqID)
+	if avail == nil || s.Decider == nil {
+		return nil, nil
+	}
+	ads, err := s.Decider.Decide(ctx, avail)
+	if err != nil {
+		return nil, err
+	}
+	b := &stitchBreak{avail: avail}
+	for _, p := range ads {
+		b.add(p, avail.Duration)
+		checkVersion(&s.out.ver, p.ver)
+	}
+	if s.Slate != nil && s.Slate.count > 0 && avail.Duration > 0 {
+		checkVersion(&s.out.ver, s.Slate.ver)
+		for n := len(b.ads); b.adsEnd < avail.Duration-stitchTolerance; n = len(b.ads) {
+			if b.add(s.Slate, avail.Duration); len(b.ads) == n {
+				break // the slate does not fit
+			}
+		}
+	}
+	if len(b.ads) == 0 {
+		return nil, nil
+	}
+	// the cues of the break go with the first ad
+	first := b.ads[0].seg
+	first.SCTE, first.DateRanges = seg.SCTE, seg.DateRanges
+	if first.ProgramDateTime.IsZero() {
+		first.ProgramDateTime = seg.ProgramDateTime
+	}
+	return b, nil
+}
+
+// add adds the segments of the ad playlist fitting in the duration of the
+// break, if known.
+func (b *stitchBreak) add(p *MediaPlaylist, duration float64) {
+	keys, init := keySet(p.Key, p.Keys), p.Map
+	first := true
+	for _, seg := range p.segments() {
+		if seg.Key != nil {
+			if keys = keySet(seg.Key, seg.Keys); seg.Key.Method == KeyMethodNone {
+				keys = nil
+			}
+		}
+		if seg.Map != nil {
+			init = seg.Map
+		}
+		if duration > 0 && b.adsEnd+seg.Duration > duration+stitchTolerance {
+			return
+		}
+		cp := *seg
+		cp.Discontinuity = first
+		cp.SCTE, cp.DateRanges = nil, nil
+		first = false
+		b.ads = append(b.ads, &stitchItem{seg: &cp, keys: keys, init: init, from: b.adsEnd, to: b.adsEnd + seg.Duration})
+		b.adsEnd += seg.Duration
+	}
+}
+
+// ends reports whether the break ends before the segment.
+func (b *stitchBreak) ends(seg *MediaSegment) bool {
+	if b.avail.Duration > 0 && b.elapsed >= b.avail.Duration-0.001 {
+		return true
+	}
+	if seg.SCTE != nil && seg.SCTE.cueType() == SCTE35CueEnd {
+		return true
+	}
+	for _, dr := range seg.DateRanges {
+		if dr.SCTE35In != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// availOf returns the ad break starting on the segment, or nil.
+func availOf(seg *MediaSegment, seqID int) *AdAvail {
+	if s := seg.SCTE; s != nil && s.cueType() == SCTE35CueStart {
+		return &AdAvail{ID: s.ID, Cue: s.Cue, Duration: s.BreakDuration(), SeqID: seqID, ProgramDateTime: seg.ProgramDateTime}
+	}
+	for _, dr := range seg.DateRanges {
+		if dr.SCTE35Out != "" {
+			duration := dr.Duration
+			if duration == 0 {
+				duration = dr.PlannedDuration
+			}
+			return &AdAvail{ID: dr.ID, Cue: dr.SCTE35Out, Duration: duration, SeqID: seqID, ProgramDateTime: dr.StartDate}
+		}
+	}
+	return nil
+}
+
+// push appends a segment to the output with the key and the map in effect
+// for it in its playlist, restating them when they differ from the ones in
+// effect in the output.
+func (s *Stitcher) push(seg *MediaSegment, anchor int, keys []*Key, init *Map) {
+	if seg.Key == nil && !sameKeys(keys, s.keys) {
+		switch {
+		case len(keys) > 0:
+			seg.Key = keys[0]
+			if len(keys) > 1 {
+				seg.Keys = keys
+			}
+		default:
+			seg.Key = &Key{Method: KeyMethodNone}
+		}
+	}
+	if seg.Key != nil {
+		if s.keys = keySet(seg.Key, seg.Keys); seg.Key.Method == KeyMethodNone {
+			s.keys = nil
+		}
+		checkKeysVersion(&s.out.ver, s.keys)
+	}
+	if seg.Map == nil && init != nil && (s.init == nil || *init != *s.init) {
+		seg.Map = init
+	}
+	if seg.Map != nil {
+		s.init = seg.Map
+	}
+	out := s.out
+	if out.count == out.capacity {
+		segs := out.segments()
+		out.Segments = make([]*MediaSegment, 2*len(segs))
+		copy(out.Segments, segs)
+		out.capacity, out.head, out.tail = len(out.Segments), 0, len(segs)
+	}
+	out.Append(seg)
+	s.anchors = append(s.anchors, anchor)
+}
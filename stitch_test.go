@@ -0,0 +1,154 @@
+package hls_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/ShevaXu/hls"
+)
+
+// adPlaylist returns a VOD ad playlist of segments of the durations.
+func adPlaylist(name string, durations ...float64) *hls.MediaPlaylist {
+	p, _ := hls.NewMediaPlaylist(0, len(durations))
+	for i, d := range durations {
+		p.Append(&hls.MediaSegment{URI: fmt.Sprintf("https://ads.example.com/%s%d.ts", name, i), Duration: d})
+	}
+	p.Close()
+	return p
+}
+
+// liveWindow returns the content window of segments first to last of a
+// stream with a 12s break starting at segment 3.
+func liveWindow(first, last int, closed bool) *hls.MediaPlaylist {
+	p, _ := hls.NewMediaPlaylist(0, last-first+1)
+	for i := first; i <= last; i++ {
+		seg := &hls.MediaSegment{URI: fmt.Sprintf("c%d.ts", i), Duration: 4}
+		switch i {
+		case 3:
+			seg.SCTE = &hls.SCTE{Syntax: hls.SyntaxOATCLS, CueType: hls.SCTE35CueStart, Time: 12}
+		case 4, 5:
+			seg.SCTE = &hls.SCTE{Syntax: hls.SyntaxOATCLS, CueType: hls.SCTE35CueMid, Time: 12, Elapsed: float64(4 * (i - 3))}
+		case 6:
+			seg.SCTE = &hls.SCTE{Syntax: hls.SyntaxOATCLS, CueType: hls.SCTE35CueEnd}
+		}
+		p.Append(seg)
+	}
+	p.SeqNo = first
+	if closed {
+		p.Close()
+	}
+	return p
+}
+
+// decoded returns the segments of the playlist as a client sees them.
+func decoded(t *testing.T, p *hls.MediaPlaylist) []*hls.MediaSegment {
+	t.Helper()
+	d := decodeMedia(t, p.String())
+	return d.Segments[:d.Count()]
+}
+
+func uris(t *testing.T, p *hls.MediaPlaylist) string {
+	var out []string
+	for _, seg := range decoded(t, p) {
+		if seg.Discontinuity {
+			out = append(out, "|")
+		}
+		out = append(out, strings.TrimPrefix(seg.URI, "https://ads.example.com/"))
+	}
+	return strings.Join(out, " ")
+}
+
+func TestStitchVOD(t *testing.T) {
+	var avail *hls.AdAvail
+	s := hls.NewStitcher(hls.AdDeciderFunc(func(_ context.Context, a *hls.AdAvail) ([]*hls.MediaPlaylist, error) {
+		avail = a
+		return []*hls.MediaPlaylist{adPlaylist("a", 5, 5)}, nil
+	}))
+	s.Slate = adPlaylist("slate", 1)
+	p, err := s.Stitch(context.Background(), liveWindow(0, 7, true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if avail == nil || avail.SeqID != 3 || avail.Duration != 12 {
+		t.Fatalf("Unexpected avail %+v", avail)
+	}
+	// 10s of ads padded with 2s of slate
+	if got, want := uris(t, p), "c0.ts c1.ts c2.ts | a0.ts a1.ts | slate0.ts | slate0.ts | c6.ts c7.ts"; got != want {
+		t.Errorf("Expected %s, got %s", want, got)
+	}
+	if segs := decoded(t, p); !p.Closed || segs[2].SCTE != nil || segs[3].SCTE == nil || segs[3].SCTE.CueType != hls.SCTE35CueStart {
+		t.Errorf("Expected the cue out on the first ad, got %+v", segs[3].SCTE)
+	}
+	if !strings.Contains(p.String(), "#EXT-X-ENDLIST") {
+		t.Errorf("Expected a closed playlist:\n%s", p)
+	}
+}
+
+func TestStitchTrim(t *testing.T) {
+	s := hls.NewStitcher(hls.AdDeciderFunc(func(context.Context, *hls.AdAvail) ([]*hls.MediaPlaylist, error) {
+		return []*hls.MediaPlaylist{adPlaylist("a", 6, 6), adPlaylist("b", 6)}, nil
+	}))
+	p, err := s.Stitch(context.Background(), liveWindow(0, 7, true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := uris(t, p), "c0.ts c1.ts c2.ts | a0.ts a1.ts | c6.ts c7.ts"; got != want {
+		t.Errorf("Expected %s, got %s", want, got)
+	}
+	// no ads keeps the content
+	s = hls.NewStitcher(hls.AdDeciderFunc(func(context.Context, *hls.AdAvail) ([]*hls.MediaPlaylist, error) {
+		return nil, nil
+	}))
+	if p, _ = s.Stitch(context.Background(), liveWindow(0, 7, true)); uris(t, p) != "c0.ts c1.ts c2.ts c3.ts c4.ts c5.ts c6.ts c7.ts" {
+		t.Errorf("Expected the content, got %s", uris(t, p))
+	}
+}
+
+func TestStitchLive(t *testing.T) {
+	decisions := 0
+	s := hls.NewStitcher(hls.AdDeciderFunc(func(context.Context, *hls.AdAvail) ([]*hls.MediaPlaylist, error) {
+		decisions++
+		if decisions == 1 {
+			return nil, errors.New("decision server down")
+		}
+		return []*hls.MediaPlaylist{adPlaylist("a", 4, 4), adPlaylist("b", 4)}, nil
+	}))
+	ctx := context.Background()
+	if _, err := s.Stitch(ctx, liveWindow(0, 3, false)); err == nil {
+		t.Fatal("Expected the decision error")
+	}
+	// seqID of every published segment by URI, which must not change
+	seqIDs := make(map[string]int)
+	for first := 0; first <= 6; first++ {
+		p, err := s.Stitch(ctx, liveWindow(first, first+3, false))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, seg := range decoded(t, p) {
+			id := p.SeqNo + i
+			if prev, ok := seqIDs[seg.URI]; ok && prev != id {
+				t.Errorf("Window %d: %s moved from %d to %d", first, seg.URI, prev, id)
+			}
+			seqIDs[seg.URI] = id
+		}
+		out := p.String()
+		if first == 6 {
+			// the window starts after the break
+			if got, want := uris(t, p), "| c6.ts c7.ts c8.ts c9.ts"; got != want {
+				t.Errorf("Expected %s, got %s", want, got)
+			}
+			if p.SeqNo != 6 || p.DiscontinuitySeq != 2 || !strings.Contains(out, "#EXT-X-DISCONTINUITY-SEQUENCE:2\n") {
+				t.Errorf("Unexpected sequence numbers %d/%d:\n%s", p.SeqNo, p.DiscontinuitySeq, out)
+			}
+		}
+	}
+	if decisions != 2 {
+		t.Errorf("Expected the break decided again once, got %d decisions", decisions)
+	}
+	if seqIDs["https://ads.example.com/a0.ts"] != 3 || seqIDs["https://ads.example.com/b0.ts"] != 5 || seqIDs["c6.ts"] != 6 {
+		t.Errorf("Unexpected sequence numbers %v", seqIDs)
+	}
+}
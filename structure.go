@@ -3,6 +3,7 @@ package hls
 import (
 	"bytes"
 	"io"
+	"sync"
 	"time"
 )
 
@@ -56,6 +57,17 @@ const (
 	Syntax672014 SCTE35Syntax = iota
 	// SyntaxOATCLS is a non-standard but common format
 	SyntaxOATCLS
+	// SyntaxElemental is the AWS Elemental format using EXT-X-CUE-OUT:DURATION=,
+	// EXT-X-CUE-OUT-CONT:<elapsed>/<duration> and EXT-X-CUE-IN
+	SyntaxElemental
+	// SyntaxAdobe is the Adobe format using EXT-X-CUE with SpliceOut and SpliceIn types
+	SyntaxAdobe
+	// SyntaxAdobeSplicePoint is the Adobe format carrying only the cue in
+	// EXT-X-SPLICEPOINT-SCTE35
+	SyntaxAdobeSplicePoint
+	// SyntaxElementalSCTE35 is the AWS Elemental format using
+	// EXT-X-SCTE35 with CUE-OUT and CUE-IN attributes
+	SyntaxElementalSCTE35
 )
 
 // SCTE35CueType defines the type of cue point, used by readers and writers to
@@ -98,25 +110,34 @@ Sample of Sliding Window Media Playlist, using HTTPS:
   https://priv.example.com/fileSequence2682.ts
 */
 type MediaPlaylist struct {
-	TargetDuration float64
-	SeqNo          int // EXT-X-MEDIA-SEQUENCE
-	Segments       []*MediaSegment
-	Args           string // optional arguments placed after URIs (URI?Args)
-	Iframe         bool   // EXT-X-I-FRAMES-ONLY
-	Closed         bool   // is this VOD (closed) or Live (sliding) playlist?
-	MediaType      MediaType
-	durationAsInt  bool // output durations as integers of floats?
-	keyformat      int
-	winsize        int // max number of segments displayed in an encoded playlist; need set to zero for VOD playlists
-	capacity       int // total capacity of slice used for the playlist
-	head           int // head of FIFO, we add segments to head
-	tail           int // tail of FIFO, we remove segments from tail
-	count          int // number of segments added to the playlist
-	buf            bytes.Buffer
-	ver            int
-	Key            *Key      // EXT-X-KEY is optional encryption key displayed before any segments (default key for the playlist)
-	Map            *Map      // EXT-X-MAP is optional tag specifies how to obtain the Media Initialization Section (default map for the playlist)
-	W              *Widevine // Widevine related tags outside of M3U8 specs
+	TargetDuration   float64
+	SeqNo            int // EXT-X-MEDIA-SEQUENCE
+	DiscontinuitySeq int // EXT-X-DISCONTINUITY-SEQUENCE
+	Segments         []*MediaSegment
+	Args             string // optional arguments placed after URIs (URI?Args), see EncodeWith for per request URIs
+	Iframe           bool   // EXT-X-I-FRAMES-ONLY
+	Closed           bool   // is this VOD (closed) or Live (sliding) playlist?
+	MediaType        MediaType
+	durationAsInt    bool // output durations as integers of floats?
+	keyformat        int
+	winsize          int // max number of segments displayed in an encoded playlist; need set to zero for VOD playlists
+	capacity         int // total capacity of slice used for the playlist
+	head             int // head of FIFO, we add segments to head
+	tail             int // tail of FIFO, we remove segments from tail
+	count            int // number of segments added to the playlist
+	buf              bytes.Buffer
+	ver              int
+	adBreak          *adBreak       // ad break tagging appended segments, see StartAdBreak
+	events           *eventHub      // subscribers to changes, see Subscribe
+	keyRotator       *KeyRotator    // key rotation of appended segments, see SetKeyRotator
+	index            *timeIndex     // lookups by time, see SegmentAt
+	indexMu          sync.Mutex     // guards index, lookups may run concurrently under LivePlaylist.View
+	Key              *Key           // EXT-X-KEY is optional encryption key displayed before any segments (default key for the playlist)
+	Keys             []*Key         // all default EXT-X-KEY tags if there are several (e.g. multi-DRM), Key is the first of them
+	ServerControl    *ServerControl // EXT-X-SERVER-CONTROL announces delta updates and blocking reloads
+	Start            *StartPoint    // EXT-X-START is the preferred point to start playing the playlist
+	Map              *Map           // EXT-X-MAP is optional tag specifies how to obtain the Media Initialization Section (default map for the playlist)
+	W                *Widevine      // Widevine related tags outside of M3U8 specs
 }
 
 // MasterPlaylist represents a master playlist which combines
@@ -137,7 +158,7 @@ type MediaPlaylist struct {
 */
 type MasterPlaylist struct {
 	Variants      []*Variant
-	Args          string // optional arguments placed after URI (URI?Args)
+	Args          string // optional arguments placed after URI (URI?Args), see EncodeWith for per request URIs
 	CypherVersion string // non-standard tag for Widevine (see also WV struct)
 	buf           bytes.Buffer
 	ver           int
@@ -188,26 +209,63 @@ type MediaSegment struct {
 	SeqID           int
 	Title           string // optional second parameter for EXTINF tag
 	URI             string
-	Duration        float64   // first parameter for EXTINF tag; duration must be integers if protocol version is less than 3 but we are always keep them float
-	Limit           int       // EXT-X-BYTERANGE <n> is length in bytes for the file under URI
-	Offset          int       // EXT-X-BYTERANGE [@o] is offset from the start of the file under URI
-	Key             *Key      // EXT-X-KEY displayed before the segment and means changing of encryption key (in theory each segment may have own key)
-	Map             *Map      // EXT-X-MAP displayed before the segment
-	Discontinuity   bool      // EXT-X-DISCONTINUITY indicates an encoding discontinuity between the media segment that follows it and the one that preceded it (i.e. file format, number and type of tracks, encoding parameters, encoding sequence, timestamp sequence)
-	SCTE            *SCTE     // SCTE-35 used for Ad signaling in HLS
-	ProgramDateTime time.Time // EXT-X-PROGRAM-DATE-TIME tag associates the first sample of a media segment with an absolute date and/or time
+	Duration        float64      // first parameter for EXTINF tag; duration must be integers if protocol version is less than 3 but we are always keep them float
+	Limit           int          // EXT-X-BYTERANGE <n> is length in bytes for the file under URI
+	Offset          int          // EXT-X-BYTERANGE [@o] is offset from the start of the file under URI
+	Key             *Key         // EXT-X-KEY displayed before the segment and means changing of encryption key (in theory each segment may have own key)
+	Keys            []*Key       // all EXT-X-KEY tags displayed before the segment if there are several (e.g. multi-DRM), Key is the first of them
+	Map             *Map         // EXT-X-MAP displayed before the segment
+	Discontinuity   bool         // EXT-X-DISCONTINUITY indicates an encoding discontinuity between the media segment that follows it and the one that preceded it (i.e. file format, number and type of tracks, encoding parameters, encoding sequence, timestamp sequence)
+	SCTE            *SCTE        // SCTE-35 used for Ad signaling in HLS
+	DateRanges      []*DateRange // EXT-X-DATERANGE tags displayed before the segment
+	ProgramDateTime time.Time    // EXT-X-PROGRAM-DATE-TIME tag associates the first sample of a media segment with an absolute date and/or time
 }
 
 // SCTE holds custom, non EXT-X-DATERANGE, SCTE-35 tags
 type SCTE struct {
-	Syntax  SCTE35Syntax  // Syntax defines the format of the SCTE-35 cue tag
-	CueType SCTE35CueType // CueType defines whether the cue is a start, mid, end (if applicable)
-	Cue     string
-	ID      string
-	Time    float64
-	Elapsed float64
+	Syntax   SCTE35Syntax  // Syntax defines the format of the SCTE-35 cue tag
+	CueType  SCTE35CueType // CueType defines whether the cue is a start, mid, end (if applicable)
+	Cue      string
+	ID       string
+	Time     float64 // TIME attribute for Syntax672014, the break duration otherwise (DURATION for SyntaxAdobe)
+	Elapsed  float64
+	Position float64 // TIME attribute for SyntaxAdobe, the presentation time of the cue
 }
 
+// DateRange represents the EXT-X-DATERANGE tag which associates a date
+// range (e.g. an ad break signalled by SCTE-35) with a set of attributes.
+// Zero values are not encoded.
+type DateRange struct {
+	ID              string
+	Class           string
+	StartDate       time.Time
+	EndDate         time.Time
+	Duration        float64
+	PlannedDuration float64
+	SCTE35Cmd       string // hex with 0x prefix
+	SCTE35Out       string // hex with 0x prefix
+	SCTE35In        string // hex with 0x prefix
+	EndOnNext       bool
+	X               map[string]string // client attributes, keys include the X- prefix
+	XQuoted         map[string]bool   // whether the client attributes are quoted strings, guessed from the values if missing
+}
+
+// Encryption methods of the EXT-X-KEY tag, see section 4.3.2.4.
+const (
+	KeyMethodNone         = "NONE"
+	KeyMethodAES128       = "AES-128"
+	KeyMethodSampleAES    = "SAMPLE-AES"
+	KeyMethodSampleAESCTR = "SAMPLE-AES-CTR"
+)
+
+// Key formats of the common key systems for the KEYFORMAT attribute.
+const (
+	KeyformatIdentity  = "identity"
+	KeyformatFairPlay  = "com.apple.streamingkeydelivery"
+	KeyformatWidevine  = "urn:uuid:edef8ba9-79d6-4ace-a3c8-27dcd51d21ed"
+	KeyformatPlayReady = "com.microsoft.playready"
+)
+
 // Key represents information about stream encryption.
 // It realizes the EXT-X-KEY tag.
 type Key struct {
@@ -218,6 +276,24 @@ type Key struct {
 	Keyformatversions string
 }
 
+// ServerControl represents the EXT-X-SERVER-CONTROL tag which allows
+// the server to indicate support for delivery directives, see section
+// 4.4.3.8 of draft-pantos-hls-rfc8216bis.
+type ServerControl struct {
+	CanSkipUntil      float64 // seconds from the end of the playlist that delta updates may skip
+	CanSkipDateRanges bool
+	HoldBack          float64
+	PartHoldBack      float64
+	CanBlockReload    bool
+}
+
+// StartPoint represents the EXT-X-START tag which indicates the preferred
+// point at which to start playing a playlist, see section 4.3.5.2 of RFC 8216.
+type StartPoint struct {
+	TimeOffset float64 // seconds from the start of the playlist, or from its end if negative
+	Precise    bool
+}
+
 // Map represents specifies how to obtain the Media Initialization Section
 // required to parse the applicable Media Segments.
 // It applies to every Media Segment that appears after it in the
@@ -266,6 +342,7 @@ type decodingState struct {
 	tagStreamInf       bool
 	tagInf             bool
 	tagSCTE35          bool
+	cueOutSyntax       SCTE35Syntax
 	tagRange           bool
 	tagDiscontinuity   bool
 	tagProgramDateTime bool
@@ -278,7 +355,8 @@ type decodingState struct {
 	title              string
 	variant            *Variant
 	alternatives       []*Alternative
-	xkey               *Key
+	xkeys              []*Key
 	xmap               *Map
 	scte               *SCTE
+	dateRanges         []*DateRange
 }
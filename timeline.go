@@ -0,0 +1,112 @@
+package hls
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+// ErrNoSegment is returned by the lookups by time outside of the playlist.
+var ErrNoSegment = errors.New("no segment at the time")
+
+// timeIndex caches the start of every segment for the lookups by time.
+// It is valid as long as the playlist keeps the same segments.
+type timeIndex struct {
+	head, count int
+	first, last *MediaSegment
+	segs        []*MediaSegment
+	offsets     []time.Duration // from the start of the first segment
+	end         time.Duration
+	times       []time.Time // wall-clock, see programDateTimes
+	dated       bool
+}
+
+// timeIndex returns the index of the segments, rebuilding it if they changed.
+// The index is never modified once built, so it can be used unlocked.
+func (p *MediaPlaylist) timeIndex() *timeIndex {
+	p.indexMu.Lock()
+	defer p.indexMu.Unlock()
+	if x := p.index; x != nil && x.head == p.head && x.count == p.count &&
+		(p.count == 0 || x.first == p.Segments[p.head] && x.last == p.Segments[(p.head+p.count-1)%p.capacity]) {
+		return x
+	}
+	segs := p.segments()
+	x := &timeIndex{head: p.head, count: p.count, segs: segs, offsets: make([]time.Duration, len(segs))}
+	if p.count > 0 {
+		x.first, x.last = p.Segments[p.head], p.Segments[(p.head+p.count-1)%p.capacity]
+	}
+	for i, seg := range segs {
+		x.offsets[i] = x.end
+		x.end += seconds(seg.Duration)
+	}
+	x.times = programDateTimes(segs)
+	x.dated = len(segs) > 0 && !x.times[0].IsZero()
+	p.index = x
+	return x
+}
+
+// SegmentAt returns the segment playing at the offset from the start of
+// the first segment of the playlist, and the offset within the segment.
+// Lookups are O(log n) with an index of the segments built on the first
+// lookup and kept until segments are appended, removed or dated with
+// SetProgramDateTime; call ResetCache after changing the durations of the
+// segments in place. Lookups are safe to run concurrently, e.g. under
+// LivePlaylist.View.
+func (p *MediaPlaylist) SegmentAt(offset time.Duration) (*MediaSegment, time.Duration, error) {
+	x := p.timeIndex()
+	if offset < 0 || offset >= x.end {
+		return nil, 0, ErrNoSegment
+	}
+	i := sort.Search(len(x.offsets), func(i int) bool { return x.offsets[i] > offset }) - 1
+	return x.segs[i], offset - x.offsets[i], nil
+}
+
+// SegmentAtTime returns the segment playing at the wall-clock time, and the
+// offset of the time within the segment. The start of the segments is
+// taken from EXT-X-PROGRAM-DATE-TIME and interpolated with the durations
+// for the segments without it, which must give increasing times.
+// Lookups are indexed as in SegmentAt.
+func (p *MediaPlaylist) SegmentAtTime(t time.Time) (*MediaSegment, time.Duration, error) {
+	x := p.timeIndex()
+	if !x.dated {
+		return nil, 0, errors.New("playlist has no EXT-X-PROGRAM-DATE-TIME")
+	}
+	i := sort.Search(len(x.times), func(i int) bool { return x.times[i].After(t) }) - 1
+	if i < 0 {
+		return nil, 0, ErrNoSegment
+	}
+	seg := x.segs[i]
+	in := t.Sub(x.times[i])
+	if in >= seconds(seg.Duration) {
+		return nil, 0, ErrNoSegment // after the end or in a gap between dates
+	}
+	return seg, in, nil
+}
+
+// programDateTimes returns the wall-clock start of every segment, taken from
+// EXT-X-PROGRAM-DATE-TIME or interpolated with the EXTINF durations from the
+// nearest segment having one. All times are zero if no segment has a date.
+func programDateTimes(segs []*MediaSegment) []time.Time {
+	times := make([]time.Time, len(segs))
+	first := -1
+	for i, seg := range segs {
+		switch {
+		case !seg.ProgramDateTime.IsZero():
+			times[i] = seg.ProgramDateTime
+			if first < 0 {
+				first = i
+			}
+		case i > 0 && !times[i-1].IsZero():
+			times[i] = times[i-1].Add(seconds(segs[i-1].Duration))
+		}
+	}
+	for i := first - 1; i >= 0; i-- {
+		times[i] = times[i+1].Add(-seconds(segs[i].Duration))
+	}
+	return times
+}
+
+// seconds converts EXTINF-like float seconds to time.Duration.
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
@@ -0,0 +1,110 @@
+package hls_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ShevaXu/hls"
+)
+
+func TestSegmentAt(t *testing.T) {
+	p, _ := hls.NewMediaPlaylist(3, 5)
+	for _, d := range []float64{4, 6, 5} {
+		p.Append(&hls.MediaSegment{URI: "seg.ts", Duration: d})
+	}
+	for _, tc := range []struct {
+		offset, in time.Duration
+		idx        int
+	}{
+		{0, 0, 0},
+		{3 * time.Second, 3 * time.Second, 0},
+		{4 * time.Second, 0, 1},
+		{11 * time.Second, time.Second, 2},
+	} {
+		seg, in, err := p.SegmentAt(tc.offset)
+		if err != nil || seg != p.Segments[tc.idx] || in != tc.in {
+			t.Errorf("%v: expected segment %d at %v, got %v %v %v", tc.offset, tc.idx, tc.in, seg, in, err)
+		}
+	}
+	if _, _, err := p.SegmentAt(15 * time.Second); err != hls.ErrNoSegment {
+		t.Errorf("Expected ErrNoSegment, got %v", err)
+	}
+	if _, _, err := p.SegmentAt(-time.Second); err != hls.ErrNoSegment {
+		t.Errorf("Expected ErrNoSegment, got %v", err)
+	}
+	// the index follows the sliding window
+	p.Remove()
+	p.Append(&hls.MediaSegment{URI: "seg.ts", Duration: 2})
+	if seg, in, err := p.SegmentAt(12 * time.Second); err != nil || seg.Duration != 2 || in != time.Second {
+		t.Errorf("Expected the appended segment, got %v %v %v", seg, in, err)
+	}
+}
+
+func TestSegmentAtTime(t *testing.T) {
+	p, _ := hls.NewMediaPlaylist(0, 5)
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 4; i++ {
+		p.Append(&hls.MediaSegment{URI: "seg.ts", Duration: 4})
+	}
+	if _, _, err := p.SegmentAtTime(start); err == nil {
+		t.Error("Expected an error without program date time")
+	}
+	// the first and the third segments are dated, with a 2s gap after the second
+	p.Segments[1].ProgramDateTime = start.Add(4 * time.Second)
+	p.Segments[2].ProgramDateTime = start.Add(10 * time.Second)
+	p.ResetCache()
+	for _, tc := range []struct {
+		at, in time.Duration
+		idx    int
+	}{
+		{0, 0, 0},
+		{5 * time.Second, time.Second, 1},
+		{10 * time.Second, 0, 2},
+		{17 * time.Second, 3 * time.Second, 3},
+	} {
+		seg, in, err := p.SegmentAtTime(start.Add(tc.at))
+		if err != nil || seg != p.Segments[tc.idx] || in != tc.in {
+			t.Errorf("%v: expected segment %d at %v, got %v %v %v", tc.at, tc.idx, tc.in, seg, in, err)
+		}
+	}
+	for _, at := range []time.Duration{-time.Second, 9 * time.Second, 18 * time.Second} {
+		if _, _, err := p.SegmentAtTime(start.Add(at)); err != hls.ErrNoSegment {
+			t.Errorf("%v: expected ErrNoSegment, got %v", at, err)
+		}
+	}
+}
+
+func TestSegmentAtTimeDatedAfterLookup(t *testing.T) {
+	p, _ := hls.NewMediaPlaylist(0, 5)
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	p.Append(&hls.MediaSegment{URI: "seg.ts", Duration: 4})
+	if _, _, err := p.SegmentAtTime(start); err == nil {
+		t.Error("Expected an error without program date time")
+	}
+	p.SetProgramDateTime(start)
+	if seg, in, err := p.SegmentAtTime(start.Add(time.Second)); err != nil || seg != p.Segments[0] || in != time.Second {
+		t.Errorf("Expected the dated segment, got %v %v %v", seg, in, err)
+	}
+}
+
+func TestSegmentAtConcurrentView(t *testing.T) {
+	l, _ := hls.NewLivePlaylist(3, 5)
+	for i := 0; i < 3; i++ {
+		l.Append(&hls.MediaSegment{URI: "seg.ts", Duration: 4})
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l.View(func(p *hls.MediaPlaylist) {
+				if _, _, err := p.SegmentAt(5 * time.Second); err != nil {
+					t.Error(err)
+				}
+			})
+		}()
+	}
+	l.Append(&hls.MediaSegment{URI: "seg.ts", Duration: 4})
+	wg.Wait()
+}
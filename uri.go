@@ -0,0 +1,213 @@
+package hls
+
+import (
+	"net/url"
+	"strings"
+)
+
+// ResolveURIs returns a copy of the playlist with the URIs of the segments,
+// keys and maps resolved against base, the URL of the playlist.
+func (p *MediaPlaylist) ResolveURIs(base *url.URL) *MediaPlaylist {
+	cp := p.clone()
+	cp.mapURIs(func(uri string) string { return resolveURI(base, uri) })
+	return cp
+}
+
+// Relativize returns a copy of the playlist with the URIs of the segments,
+// keys and maps made relative to base, the URL the playlist is published at.
+// URIs on another host or which are already relative are left as is.
+func (p *MediaPlaylist) Relativize(base *url.URL) *MediaPlaylist {
+	cp := p.clone()
+	cp.mapURIs(func(uri string) string { return relativeURI(base, uri) })
+	return cp
+}
+
+// mapURIs replaces every URI of the playlist with fn(URI).
+// Keys shared by several segments are mapped once.
+func (p *MediaPlaylist) mapURIs(fn func(string) string) {
+	done := make(map[*Key]bool)
+	mapKey := func(key *Key) {
+		if key != nil && !done[key] {
+			key.URI = fn(key.URI)
+			done[key] = true
+		}
+	}
+	mapKey(p.Key)
+	for _, key := range p.Keys {
+		mapKey(key)
+	}
+	if p.Map != nil {
+		p.Map.URI = fn(p.Map.URI)
+	}
+	for _, seg := range p.segments() {
+		seg.URI = fn(seg.URI)
+		mapKey(seg.Key)
+		for _, key := range seg.Keys {
+			mapKey(key)
+		}
+		if seg.Map != nil {
+			seg.Map.URI = fn(seg.Map.URI)
+		}
+	}
+	p.buf.Reset()
+}
+
+// ResolveURIs returns a copy of the playlist with the URIs of the variants
+// and alternatives resolved against base, the URL of the master playlist.
+// The URIs of the media playlists of the variants are resolved against
+// the resolved URI of their variant.
+func (p *MasterPlaylist) ResolveURIs(base *url.URL) *MasterPlaylist {
+	cp := p.clone()
+	cp.mapURIs(base, resolveURI)
+	return cp
+}
+
+// Relativize returns a copy of the playlist with the URIs of the variants
+// and alternatives made relative to base, the URL the master playlist is
+// published at. The URIs of the media playlists of the variants are made
+// relative to the absolute URL of their variant.
+func (p *MasterPlaylist) Relativize(base *url.URL) *MasterPlaylist {
+	cp := p.clone()
+	cp.mapURIs(base, relativeURI)
+	return cp
+}
+
+// mapURIs replaces the URIs of the variants and alternatives with
+// fn(base, URI) and the URIs of their media playlists with fn(variant, URI),
+// variant being the absolute URL of the variant.
+func (p *MasterPlaylist) mapURIs(base *url.URL, fn func(*url.URL, string) string) {
+	done := make(map[*Alternative]bool)
+	for _, v := range p.Variants {
+		if v.Chunklist != nil {
+			if u, err := base.Parse(v.URI); err == nil {
+				v.Chunklist.mapURIs(func(uri string) string { return fn(u, uri) })
+			}
+		}
+		v.URI = fn(base, v.URI)
+		for _, alt := range v.Alternatives {
+			if !done[alt] {
+				alt.URI = fn(base, alt.URI)
+				done[alt] = true
+			}
+		}
+	}
+	p.buf.Reset()
+}
+
+// resolveURI resolves uri against base, it returns invalid URIs as is.
+func resolveURI(base *url.URL, uri string) string {
+	if uri == "" {
+		return uri
+	}
+	u, err := base.Parse(uri)
+	if err != nil {
+		return uri
+	}
+	return u.String()
+}
+
+// relativeURI returns uri relative to base if both are on the same host.
+func relativeURI(base *url.URL, uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || !u.IsAbs() || u.Scheme != base.Scheme || u.Host != base.Host || u.User.String() != base.User.String() {
+		return uri
+	}
+	from := strings.Split(base.EscapedPath(), "/")
+	from = from[:len(from)-1] // directory of base
+	to := strings.Split(u.EscapedPath(), "/")
+	i := 0
+	for i < len(from) && i < len(to)-1 && from[i] == to[i] {
+		i++
+	}
+	rel := strings.Repeat("../", len(from)-i) + strings.Join(to[i:], "/")
+	if rel == "" {
+		rel = "./"
+	}
+	if strings.Contains(strings.SplitN(rel, "/", 2)[0], ":") {
+		rel = "./" + rel // the first segment would be taken for a scheme
+	}
+	if u.RawQuery != "" || u.ForceQuery {
+		rel += "?" + u.RawQuery
+	}
+	if u.Fragment != "" {
+		rel += "#" + u.EscapedFragment()
+	}
+	return rel
+}
+
+// URIKind tells which kind of resource a URI refers to.
+type URIKind int
+
+// Kinds of URIs passed to URIRewriter.
+const (
+	URISegment   URIKind = iota + 1 // media segment
+	URIKey                          // EXT-X-KEY
+	URIMap                          // EXT-X-MAP
+	URIVariant                      // EXT-X-STREAM-INF or EXT-X-I-FRAME-STREAM-INF
+	URIRendition                    // EXT-X-MEDIA
+)
+
+// String returns the name of the kind.
+func (k URIKind) String() string {
+	switch k {
+	case URISegment:
+		return "segment"
+	case URIKey:
+		return "key"
+	case URIMap:
+		return "map"
+	case URIVariant:
+		return "variant"
+	case URIRendition:
+		return "rendition"
+	}
+	return "unknown"
+}
+
+// URIRewriter rewrites URIs while a playlist is encoded, e.g. to sign them,
+// to swap the CDN host or to add per user tokens, see EncodeWith.
+type URIRewriter interface {
+	RewriteURI(kind URIKind, uri string) string
+}
+
+// URIRewriterFunc adapts a function to the URIRewriter interface.
+type URIRewriterFunc func(kind URIKind, uri string) string
+
+// RewriteURI calls f(kind, uri).
+func (f URIRewriterFunc) RewriteURI(kind URIKind, uri string) string {
+	return f(kind, uri)
+}
+
+// QueryRewriter returns a rewriter appending the query to every URI.
+func QueryRewriter(query string) URIRewriter {
+	return URIRewriterFunc(func(_ URIKind, uri string) string {
+		return AppendQuery(uri, query)
+	})
+}
+
+// AppendQuery appends the query to the URI, after '?' or '&' depending on
+// whether the URI already has a query.
+func AppendQuery(uri, query string) string {
+	if query == "" {
+		return uri
+	}
+	fragment := ""
+	if i := strings.IndexByte(uri, '#'); i >= 0 {
+		uri, fragment = uri[:i], uri[i:]
+	}
+	switch {
+	case !strings.Contains(uri, "?"):
+		uri += "?"
+	case !strings.HasSuffix(uri, "?") && !strings.HasSuffix(uri, "&"):
+		uri += "&"
+	}
+	return uri + query + fragment
+}
+
+// rewriteURI rewrites uri with rw if not nil.
+func rewriteURI(rw URIRewriter, kind URIKind, uri string) string {
+	if rw == nil || uri == "" {
+		return uri
+	}
+	return rw.RewriteURI(kind, uri)
+}
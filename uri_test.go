@@ -0,0 +1,140 @@
+package hls_test
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/ShevaXu/hls"
+)
+
+func TestMediaPlaylistResolveURIs(t *testing.T) {
+	p, err := hls.NewMediaPlaylist(3, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.SetDefaultMap("init.mp4", 0, 0)
+	p.Append(hls.QuickSegment("seg0.ts", "", 4))
+	p.SetKey("AES-128", "/keys/0.key", "", "", "")
+	p.Append(hls.QuickSegment("https://cdn.example.org/seg1.ts", "", 4))
+	p.Append(hls.QuickSegment("../other/seg2.ts?token=1", "", 4))
+
+	base, _ := url.Parse("https://example.com/live/hd/index.m3u8")
+	abs := p.ResolveURIs(base)
+	expected := []string{
+		"https://example.com/live/hd/seg0.ts",
+		"https://cdn.example.org/seg1.ts",
+		"https://example.com/live/other/seg2.ts?token=1",
+	}
+	for i, seg := range abs.Segments[:abs.Count()] {
+		if seg.URI != expected[i] {
+			t.Errorf("Expected %s, got %s", expected[i], seg.URI)
+		}
+	}
+	if abs.Map.URI != "https://example.com/live/hd/init.mp4" || abs.Segments[0].Key.URI != "https://example.com/keys/0.key" {
+		t.Errorf("Unexpected map %s or key %s", abs.Map.URI, abs.Segments[0].Key.URI)
+	}
+	if p.Segments[0].URI != "seg0.ts" || p.Segments[0].Key.URI != "/keys/0.key" {
+		t.Error("ResolveURIs changed the original playlist")
+	}
+
+	rel := abs.Relativize(base)
+	expected = []string{"seg0.ts", "https://cdn.example.org/seg1.ts", "../other/seg2.ts?token=1"}
+	for i, seg := range rel.Segments[:rel.Count()] {
+		if seg.URI != expected[i] {
+			t.Errorf("Expected %s, got %s", expected[i], seg.URI)
+		}
+	}
+	if rel.Segments[0].Key.URI != "../../keys/0.key" {
+		t.Errorf("Unexpected key %s", rel.Segments[0].Key.URI)
+	}
+}
+
+func TestMasterPlaylistResolveURIs(t *testing.T) {
+	media, _ := hls.NewMediaPlaylist(1, 1)
+	media.Append(hls.QuickSegment("seg0.ts", "", 4))
+	audio := &hls.Alternative{GroupID: "aud", Type: "AUDIO", URI: "audio/en.m3u8"}
+	m := hls.NewMasterPlaylist()
+	m.Append("hd/index.m3u8", media, hls.VariantParams{Bandwidth: 1000000, Alternatives: []*hls.Alternative{audio}})
+	m.Append("sd/index.m3u8", nil, hls.VariantParams{Bandwidth: 500000, Alternatives: []*hls.Alternative{audio}})
+
+	base, _ := url.Parse("https://example.com/vod/master.m3u8")
+	abs := m.ResolveURIs(base)
+	if abs.Variants[0].URI != "https://example.com/vod/hd/index.m3u8" {
+		t.Errorf("Unexpected variant %s", abs.Variants[0].URI)
+	}
+	if abs.Variants[0].Chunklist.Segments[0].URI != "https://example.com/vod/hd/seg0.ts" {
+		t.Errorf("Unexpected segment %s", abs.Variants[0].Chunklist.Segments[0].URI)
+	}
+	if alt := abs.Variants[1].Alternatives[0]; alt != abs.Variants[0].Alternatives[0] || alt.URI != "https://example.com/vod/audio/en.m3u8" {
+		t.Errorf("Unexpected alternative %+v", alt)
+	}
+	if media.Segments[0].URI != "seg0.ts" || audio.URI != "audio/en.m3u8" {
+		t.Error("ResolveURIs changed the original playlist")
+	}
+
+	published, _ := url.Parse("https://example.com/archive/vod/master.m3u8")
+	rel := abs.Relativize(published)
+	if rel.Variants[0].URI != "../../vod/hd/index.m3u8" || rel.Variants[0].Chunklist.Segments[0].URI != "seg0.ts" {
+		t.Errorf("Unexpected relative URIs %s %s", rel.Variants[0].URI, rel.Variants[0].Chunklist.Segments[0].URI)
+	}
+}
+
+func TestMediaPlaylistEncodeWith(t *testing.T) {
+	p, err := hls.NewMediaPlaylist(3, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.SetDefaultMap("init.mp4", 0, 0)
+	p.Append(hls.QuickSegment("seg0.ts?v=1", "", 4))
+	p.SetKey("AES-128", "key0", "", "", "")
+	p.Args = "user=1"
+	plain := p.String()
+	rw := hls.URIRewriterFunc(func(kind hls.URIKind, uri string) string {
+		return "https://cdn.example.com/" + hls.AppendQuery(uri, "kind="+kind.String())
+	})
+	out := p.EncodeWith(rw).String()
+	for _, exp := range []string{
+		`#EXT-X-MAP:URI="https://cdn.example.com/init.mp4?kind=map"`,
+		`#EXT-X-KEY:METHOD=AES-128,URI="https://cdn.example.com/key0?kind=key"`,
+		"\nhttps://cdn.example.com/seg0.ts?v=1&user=1&kind=segment\n",
+	} {
+		if !strings.Contains(out, exp) {
+			t.Errorf("Expected %q in\n%s", exp, out)
+		}
+	}
+	if p.String() != plain || !strings.Contains(plain, "\nseg0.ts?v=1&user=1\n") {
+		t.Errorf("EncodeWith changed the cached playlist:\n%s", p.String())
+	}
+}
+
+func TestMasterPlaylistEncodeWith(t *testing.T) {
+	m := hls.NewMasterPlaylist()
+	audio := &hls.Alternative{GroupID: "aud", Type: "AUDIO", Name: "en", URI: "audio/en.m3u8"}
+	m.Append("hd/index.m3u8", nil, hls.VariantParams{Bandwidth: 1000000, Audio: "aud", Alternatives: []*hls.Alternative{audio}})
+	m.Append("hd/iframes.m3u8", nil, hls.VariantParams{Bandwidth: 100000, Iframe: true})
+	out := m.EncodeWith(hls.QueryRewriter("token=abc")).String()
+	for _, exp := range []string{
+		`URI="audio/en.m3u8?token=abc"`,
+		`URI="hd/iframes.m3u8?token=abc"`,
+		"\nhd/index.m3u8?token=abc\n",
+	} {
+		if !strings.Contains(out, exp) {
+			t.Errorf("Expected %q in\n%s", exp, out)
+		}
+	}
+}
+
+func TestAppendQuery(t *testing.T) {
+	for _, test := range [][3]string{
+		{"a.ts", "t=1", "a.ts?t=1"},
+		{"a.ts?v=2", "t=1", "a.ts?v=2&t=1"},
+		{"a.ts?", "t=1", "a.ts?t=1"},
+		{"a.ts#frag", "t=1", "a.ts?t=1#frag"},
+		{"a.ts", "", "a.ts"},
+	} {
+		if got := hls.AppendQuery(test[0], test[1]); got != test[2] {
+			t.Errorf("AppendQuery(%q, %q) = %q, expected %q", test[0], test[1], got, test[2])
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -68,10 +69,23 @@ func (p *MasterPlaylist) Encode() *bytes.Buffer {
 	if p.buf.Len() > 0 {
 		return &p.buf
 	}
+	p.encode(&p.buf, nil)
+	return &p.buf
+}
+
+// EncodeWith generates the output in M3U8 format into a new buffer,
+// rewriting every URI with rw. The playlist cache is left untouched.
+func (p *MasterPlaylist) EncodeWith(rw URIRewriter) *bytes.Buffer {
+	buf := new(bytes.Buffer)
+	p.encode(buf, rw)
+	return buf
+}
 
-	p.buf.WriteString("#EXTM3U\n#EXT-X-VERSION:")
-	p.buf.WriteString(strconv.Itoa(p.ver))
-	p.buf.WriteRune('\n')
+// encode writes the playlist to buf, rewriting URIs with rw if not nil.
+func (p *MasterPlaylist) encode(buf *bytes.Buffer, rw URIRewriter) {
+	buf.WriteString("#EXTM3U\n#EXT-X-VERSION:")
+	buf.WriteString(strconv.Itoa(p.ver))
+	buf.WriteRune('\n')
 
 	var altsWritten = make(map[string]bool)
 
@@ -85,143 +99,137 @@ func (p *MasterPlaylist) Encode() *bytes.Buffer {
 				}
 				altsWritten[altKey] = true
 
-				p.buf.WriteString("#EXT-X-MEDIA:")
+				buf.WriteString("#EXT-X-MEDIA:")
 				if alt.Type != "" {
-					p.buf.WriteString("TYPE=") // Type should not be quoted
-					p.buf.WriteString(alt.Type)
+					buf.WriteString("TYPE=") // Type should not be quoted
+					buf.WriteString(alt.Type)
 				}
 				if alt.GroupID != "" {
-					p.buf.WriteString(",GROUP-ID=\"")
-					p.buf.WriteString(alt.GroupID)
-					p.buf.WriteRune('"')
+					buf.WriteString(",GROUP-ID=\"")
+					buf.WriteString(alt.GroupID)
+					buf.WriteRune('"')
 				}
 				if alt.Name != "" {
-					p.buf.WriteString(",NAME=\"")
-					p.buf.WriteString(alt.Name)
-					p.buf.WriteRune('"')
+					buf.WriteString(",NAME=\"")
+					buf.WriteString(alt.Name)
+					buf.WriteRune('"')
 				}
-				p.buf.WriteString(",DEFAULT=")
+				buf.WriteString(",DEFAULT=")
 				if alt.Default {
-					p.buf.WriteString("YES")
+					buf.WriteString("YES")
 				} else {
-					p.buf.WriteString("NO")
+					buf.WriteString("NO")
 				}
 				if alt.Autoselect != "" {
-					p.buf.WriteString(",AUTOSELECT=")
-					p.buf.WriteString(alt.Autoselect)
+					buf.WriteString(",AUTOSELECT=")
+					buf.WriteString(alt.Autoselect)
 				}
 				if alt.Language != "" {
-					p.buf.WriteString(",LANGUAGE=\"")
-					p.buf.WriteString(alt.Language)
-					p.buf.WriteRune('"')
+					buf.WriteString(",LANGUAGE=\"")
+					buf.WriteString(alt.Language)
+					buf.WriteRune('"')
 				}
 				if alt.Forced != "" {
-					p.buf.WriteString(",FORCED=\"")
-					p.buf.WriteString(alt.Forced)
-					p.buf.WriteRune('"')
+					buf.WriteString(",FORCED=\"")
+					buf.WriteString(alt.Forced)
+					buf.WriteRune('"')
 				}
 				if alt.Characteristics != "" {
-					p.buf.WriteString(",CHARACTERISTICS=\"")
-					p.buf.WriteString(alt.Characteristics)
-					p.buf.WriteRune('"')
+					buf.WriteString(",CHARACTERISTICS=\"")
+					buf.WriteString(alt.Characteristics)
+					buf.WriteRune('"')
 				}
 				if alt.Subtitles != "" {
-					p.buf.WriteString(",SUBTITLES=\"")
-					p.buf.WriteString(alt.Subtitles)
-					p.buf.WriteRune('"')
+					buf.WriteString(",SUBTITLES=\"")
+					buf.WriteString(alt.Subtitles)
+					buf.WriteRune('"')
 				}
 				if alt.URI != "" {
-					p.buf.WriteString(",URI=\"")
-					p.buf.WriteString(alt.URI)
-					p.buf.WriteRune('"')
+					buf.WriteString(",URI=\"")
+					buf.WriteString(rewriteURI(rw, URIRendition, alt.URI))
+					buf.WriteRune('"')
 				}
-				p.buf.WriteRune('\n')
+				buf.WriteRune('\n')
 			}
 		}
 		if pl.Iframe {
-			p.buf.WriteString("#EXT-X-I-FRAME-STREAM-INF:PROGRAM-ID=")
-			p.buf.WriteString(strconv.FormatUint(uint64(pl.ProgramID), 10))
-			p.buf.WriteString(",BANDWIDTH=")
-			p.buf.WriteString(strconv.FormatUint(uint64(pl.Bandwidth), 10))
+			buf.WriteString("#EXT-X-I-FRAME-STREAM-INF:PROGRAM-ID=")
+			buf.WriteString(strconv.FormatUint(uint64(pl.ProgramID), 10))
+			buf.WriteString(",BANDWIDTH=")
+			buf.WriteString(strconv.FormatUint(uint64(pl.Bandwidth), 10))
 			if pl.Codecs != "" {
-				p.buf.WriteString(",CODECS=\"")
-				p.buf.WriteString(pl.Codecs)
-				p.buf.WriteRune('"')
+				buf.WriteString(",CODECS=\"")
+				buf.WriteString(pl.Codecs)
+				buf.WriteRune('"')
 			}
 			if pl.Resolution != "" {
-				p.buf.WriteString(",RESOLUTION=") // Resolution should not be quoted
-				p.buf.WriteString(pl.Resolution)
+				buf.WriteString(",RESOLUTION=") // Resolution should not be quoted
+				buf.WriteString(pl.Resolution)
 			}
 			if pl.Video != "" {
-				p.buf.WriteString(",VIDEO=\"")
-				p.buf.WriteString(pl.Video)
-				p.buf.WriteRune('"')
+				buf.WriteString(",VIDEO=\"")
+				buf.WriteString(pl.Video)
+				buf.WriteRune('"')
 			}
 			if pl.URI != "" {
-				p.buf.WriteString(",URI=\"")
-				p.buf.WriteString(pl.URI)
-				p.buf.WriteRune('"')
+				buf.WriteString(",URI=\"")
+				buf.WriteString(rewriteURI(rw, URIVariant, pl.URI))
+				buf.WriteRune('"')
 			}
-			p.buf.WriteRune('\n')
+			buf.WriteRune('\n')
 		} else {
-			p.buf.WriteString("#EXT-X-STREAM-INF:PROGRAM-ID=")
-			p.buf.WriteString(strconv.FormatUint(uint64(pl.ProgramID), 10))
-			p.buf.WriteString(",BANDWIDTH=")
-			p.buf.WriteString(strconv.FormatUint(uint64(pl.Bandwidth), 10))
+			buf.WriteString("#EXT-X-STREAM-INF:PROGRAM-ID=")
+			buf.WriteString(strconv.FormatUint(uint64(pl.ProgramID), 10))
+			buf.WriteString(",BANDWIDTH=")
+			buf.WriteString(strconv.FormatUint(uint64(pl.Bandwidth), 10))
 			if pl.Codecs != "" {
-				p.buf.WriteString(",CODECS=\"")
-				p.buf.WriteString(pl.Codecs)
-				p.buf.WriteRune('"')
+				buf.WriteString(",CODECS=\"")
+				buf.WriteString(pl.Codecs)
+				buf.WriteRune('"')
 			}
 			if pl.Resolution != "" {
-				p.buf.WriteString(",RESOLUTION=") // Resolution should not be quoted
-				p.buf.WriteString(pl.Resolution)
+				buf.WriteString(",RESOLUTION=") // Resolution should not be quoted
+				buf.WriteString(pl.Resolution)
 			}
 			if pl.Audio != "" {
-				p.buf.WriteString(",AUDIO=\"")
-				p.buf.WriteString(pl.Audio)
-				p.buf.WriteRune('"')
+				buf.WriteString(",AUDIO=\"")
+				buf.WriteString(pl.Audio)
+				buf.WriteRune('"')
 			}
 			if pl.Video != "" {
-				p.buf.WriteString(",VIDEO=\"")
-				p.buf.WriteString(pl.Video)
-				p.buf.WriteRune('"')
+				buf.WriteString(",VIDEO=\"")
+				buf.WriteString(pl.Video)
+				buf.WriteRune('"')
 			}
 			if pl.Captions != "" {
-				p.buf.WriteString(",CLOSED-CAPTIONS=")
+				buf.WriteString(",CLOSED-CAPTIONS=")
 				if pl.Captions == "NONE" {
-					p.buf.WriteString(pl.Captions) // CC should not be quoted when eq NONE
+					buf.WriteString(pl.Captions) // CC should not be quoted when eq NONE
 				} else {
-					p.buf.WriteRune('"')
-					p.buf.WriteString(pl.Captions)
-					p.buf.WriteRune('"')
+					buf.WriteRune('"')
+					buf.WriteString(pl.Captions)
+					buf.WriteRune('"')
 				}
 			}
 			if pl.Subtitles != "" {
-				p.buf.WriteString(",SUBTITLES=\"")
-				p.buf.WriteString(pl.Subtitles)
-				p.buf.WriteRune('"')
+				buf.WriteString(",SUBTITLES=\"")
+				buf.WriteString(pl.Subtitles)
+				buf.WriteRune('"')
 			}
 			if pl.Name != "" {
-				p.buf.WriteString(",NAME=\"")
-				p.buf.WriteString(pl.Name)
-				p.buf.WriteRune('"')
+				buf.WriteString(",NAME=\"")
+				buf.WriteString(pl.Name)
+				buf.WriteRune('"')
 			}
-			p.buf.WriteRune('\n')
-			p.buf.WriteString(pl.URI)
+			buf.WriteRune('\n')
+			uri := pl.URI
 			if p.Args != "" {
-				if strings.Contains(pl.URI, "?") {
-					p.buf.WriteRune('&')
-				} else {
-					p.buf.WriteRune('?')
-				}
-				p.buf.WriteString(p.Args)
+				uri = AppendQuery(uri, p.Args)
 			}
-			p.buf.WriteRune('\n')
+			buf.WriteString(rewriteURI(rw, URIVariant, uri))
+			buf.WriteRune('\n')
 		}
 	}
-
-	return &p.buf
 }
 
 // Version returns the current playlist version number
@@ -263,6 +271,18 @@ func (p *MediaPlaylist) last() int {
 	return p.tail - 1
 }
 
+// segments returns the segments currently in the playlist in order,
+// skipping empty slots.
+func (p *MediaPlaylist) segments() []*MediaSegment {
+	out := make([]*MediaSegment, 0, p.count)
+	for i := 0; i < p.count; i++ {
+		if seg := p.Segments[(p.head+i)%p.capacity]; seg != nil {
+			out = append(out, seg)
+		}
+	}
+	return out
+}
+
 // Remove removes a segment from the head of chunk slice form a media playlist.
 // The removed segment will return for further use.
 // This operation does reset playlist cache.
@@ -271,12 +291,20 @@ func (p *MediaPlaylist) Remove() (removed *MediaSegment, err error) {
 		return nil, errors.New("playlist is empty")
 	}
 	removed = p.Segments[p.head]
+	seqID := p.SeqNo
 	p.head = (p.head + 1) % p.capacity
 	p.count--
 	if !p.Closed {
 		p.SeqNo++
+		if removed.Discontinuity {
+			p.DiscontinuitySeq++
+		}
+	}
+	if p.keyRotator != nil {
+		p.carryKey(removed)
 	}
 	p.buf.Reset()
+	p.publish(EventRemove, removed, seqID)
 	return
 }
 
@@ -286,6 +314,12 @@ func (p *MediaPlaylist) Append(seg *MediaSegment) error {
 	if p.head == p.tail && p.count > 0 {
 		return ErrPlaylistFull
 	}
+	p.tagAdBreak(seg)
+	if p.keyRotator != nil {
+		if err := p.rotateKey(seg, p.SeqNo+p.count); err != nil {
+			return err
+		}
+	}
 	p.Segments[p.tail] = seg
 	p.tail = (p.tail + 1) % p.capacity
 	p.count++
@@ -293,6 +327,7 @@ func (p *MediaPlaylist) Append(seg *MediaSegment) error {
 		p.TargetDuration = math.Ceil(seg.Duration)
 	}
 	p.buf.Reset()
+	p.publish(EventAppend, seg, p.SeqNo+p.count-1)
 	return nil
 }
 
@@ -324,9 +359,11 @@ func (p *MediaPlaylist) Slide(seg *MediaSegment) (removed *MediaSegment, err err
 }
 
 // ResetCache resets the playlist cache, so that
-// next call on Encode() will regenerate playlist from the chunk slice.
+// next call on Encode() will regenerate playlist from the chunk slice
+// and lookups by time will reindex the segments.
 func (p *MediaPlaylist) ResetCache() {
 	p.buf.Reset()
+	p.index = nil
 }
 
 // Encode generate output in M3U8 format.
@@ -335,134 +372,192 @@ func (p *MediaPlaylist) Encode() *bytes.Buffer {
 	if p.buf.Len() > 0 {
 		return &p.buf
 	}
+	p.encode(&p.buf, 0, false, nil)
+	return &p.buf
+}
 
-	p.buf.WriteString("#EXTM3U\n#EXT-X-VERSION:")
-	p.buf.WriteString(strconv.Itoa(p.ver))
-	p.buf.WriteRune('\n')
-	// default key (workaround for Widevine)
-	if p.Key != nil {
-		p.buf.WriteString("#EXT-X-KEY:")
-		p.buf.WriteString("METHOD=")
-		p.buf.WriteString(p.Key.Method)
-		if p.Key.Method != "NONE" {
-			p.buf.WriteString(",URI=\"")
-			p.buf.WriteString(p.Key.URI)
-			p.buf.WriteRune('"')
-			if p.Key.IV != "" {
-				p.buf.WriteString(",IV=")
-				p.buf.WriteString(p.Key.IV)
-			}
-			if p.Key.Keyformat != "" {
-				p.buf.WriteString(",KEYFORMAT=\"")
-				p.buf.WriteString(p.Key.Keyformat)
-				p.buf.WriteRune('"')
-			}
-			if p.Key.Keyformatversions != "" {
-				p.buf.WriteString(",KEYFORMATVERSIONS=\"")
-				p.buf.WriteString(p.Key.Keyformatversions)
-				p.buf.WriteRune('"')
-			}
+// EncodeWith generates output in M3U8 format into a new buffer, rewriting
+// every segment, key and map URI with rw, e.g. to sign them per request.
+// The playlist cache is left untouched.
+func (p *MediaPlaylist) EncodeWith(rw URIRewriter) *bytes.Buffer {
+	buf := new(bytes.Buffer)
+	p.encode(buf, 0, false, rw)
+	return buf
+}
+
+// EncodeDelta generates a Playlist Delta Update (section 6.2.5.1 of
+// draft-pantos-hls-rfc8216bis) in a new buffer: the segments older than
+// ServerControl.CanSkipUntil seconds from the end of the playlist are
+// replaced by an EXT-X-SKIP tag. The tags of the skipped segments are left
+// out, apart from EXT-X-DATERANGE unless skipDateRanges is true (_HLS_skip=v2)
+// and ServerControl.CanSkipDateRanges is set. It encodes the full playlist
+// if the playlist can not be skipped.
+func (p *MediaPlaylist) EncodeDelta(skipDateRanges bool) *bytes.Buffer {
+	buf := new(bytes.Buffer)
+	skip := p.skippable()
+	p.encode(buf, skip, skip > 0 && skipDateRanges && p.ServerControl.CanSkipDateRanges, nil)
+	return buf
+}
+
+// skippable returns the number of segments a delta update can skip.
+func (p *MediaPlaylist) skippable() int {
+	if p.ServerControl == nil || p.ServerControl.CanSkipUntil <= 0 {
+		return 0
+	}
+	segs := p.segments()
+	if p.winsize > 0 && len(segs) > p.winsize {
+		segs = segs[:p.winsize]
+	}
+	var remaining float64
+	for _, seg := range segs {
+		remaining += seg.Duration
+	}
+	skip := 0
+	for _, seg := range segs {
+		if remaining <= p.ServerControl.CanSkipUntil {
+			break
 		}
-		p.buf.WriteRune('\n')
+		remaining -= seg.Duration
+		skip++
+	}
+	return skip
+}
+
+// encode writes the playlist to buf, replacing the first skip segments
+// with EXT-X-SKIP, and their date ranges too if skipDateRanges is true,
+// and rewriting URIs with rw if not nil.
+func (p *MediaPlaylist) encode(buf *bytes.Buffer, skip int, skipDateRanges bool, rw URIRewriter) {
+	ver := p.ver
+	switch {
+	case skipDateRanges:
+		// skipping EXT-X-DATERANGE requires version 10, see section 7 of draft-pantos-hls-rfc8216bis
+		checkVersion(&ver, 10)
+	case skip > 0:
+		// EXT-X-SKIP requires version 9, see section 7 of draft-pantos-hls-rfc8216bis
+		checkVersion(&ver, 9)
+	}
+	buf.WriteString("#EXTM3U\n#EXT-X-VERSION:")
+	buf.WriteString(strconv.Itoa(ver))
+	buf.WriteRune('\n')
+	// default keys (workaround for Widevine)
+	defaultKeys := keySet(p.Key, p.Keys)
+	for _, key := range defaultKeys {
+		writeKey(buf, key, rw)
 	}
 	if p.Map != nil {
-		p.buf.WriteString("#EXT-X-MAP:")
-		p.buf.WriteString("URI=\"")
-		p.buf.WriteString(p.Map.URI)
-		p.buf.WriteRune('"')
+		buf.WriteString("#EXT-X-MAP:")
+		buf.WriteString("URI=\"")
+		buf.WriteString(rewriteURI(rw, URIMap, p.Map.URI))
+		buf.WriteRune('"')
 		if p.Map.Limit > 0 {
-			p.buf.WriteString(",BYTERANGE=")
-			p.buf.WriteString(strconv.Itoa(p.Map.Limit))
-			p.buf.WriteRune('@')
-			p.buf.WriteString(strconv.Itoa(p.Map.Offset))
+			buf.WriteString(",BYTERANGE=")
+			buf.WriteString(strconv.Itoa(p.Map.Limit))
+			buf.WriteRune('@')
+			buf.WriteString(strconv.Itoa(p.Map.Offset))
 		}
-		p.buf.WriteRune('\n')
+		buf.WriteRune('\n')
 	}
 	if p.MediaType > 0 {
-		p.buf.WriteString("#EXT-X-PLAYLIST-TYPE:")
+		buf.WriteString("#EXT-X-PLAYLIST-TYPE:")
 		switch p.MediaType {
 		case MediaTypeEvent:
-			p.buf.WriteString("EVENT\n")
-			p.buf.WriteString("#EXT-X-ALLOW-CACHE:NO\n")
+			buf.WriteString("EVENT\n")
+			buf.WriteString("#EXT-X-ALLOW-CACHE:NO\n")
 		case MediaTypeVOD:
-			p.buf.WriteString("VOD\n")
+			buf.WriteString("VOD\n")
+		}
+	}
+	buf.WriteString("#EXT-X-MEDIA-SEQUENCE:")
+	buf.WriteString(strconv.Itoa(p.SeqNo))
+	buf.WriteRune('\n')
+	if p.DiscontinuitySeq != 0 {
+		buf.WriteString("#EXT-X-DISCONTINUITY-SEQUENCE:")
+		buf.WriteString(strconv.Itoa(p.DiscontinuitySeq))
+		buf.WriteRune('\n')
+	}
+	buf.WriteString("#EXT-X-TARGETDURATION:")
+	buf.WriteString(strconv.FormatInt(int64(math.Ceil(p.TargetDuration)), 10)) // due section 3.4.2 of M3U8 specs EXT-X-TARGETDURATION must be integer
+	buf.WriteRune('\n')
+	if p.ServerControl != nil {
+		writeServerControl(buf, p.ServerControl)
+	}
+	if p.Start != nil {
+		buf.WriteString("#EXT-X-START:TIME-OFFSET=")
+		buf.WriteString(strconv.FormatFloat(p.Start.TimeOffset, 'f', -1, 64))
+		if p.Start.Precise {
+			buf.WriteString(",PRECISE=YES")
 		}
+		buf.WriteRune('\n')
 	}
-	p.buf.WriteString("#EXT-X-MEDIA-SEQUENCE:")
-	p.buf.WriteString(strconv.Itoa(p.SeqNo))
-	p.buf.WriteRune('\n')
-	p.buf.WriteString("#EXT-X-TARGETDURATION:")
-	p.buf.WriteString(strconv.FormatInt(int64(math.Ceil(p.TargetDuration)), 10)) // due section 3.4.2 of M3U8 specs EXT-X-TARGETDURATION must be integer
-	p.buf.WriteRune('\n')
 	if p.Iframe {
-		p.buf.WriteString("#EXT-X-I-FRAMES-ONLY\n")
+		buf.WriteString("#EXT-X-I-FRAMES-ONLY\n")
 	}
 	// Widevine tags
 	if p.W != nil {
 		if p.W.AudioChannels != 0 {
-			p.buf.WriteString("#WV-AUDIO-CHANNELS ")
-			p.buf.WriteString(strconv.FormatUint(uint64(p.W.AudioChannels), 10))
-			p.buf.WriteRune('\n')
+			buf.WriteString("#WV-AUDIO-CHANNELS ")
+			buf.WriteString(strconv.FormatUint(uint64(p.W.AudioChannels), 10))
+			buf.WriteRune('\n')
 		}
 		if p.W.AudioFormat != 0 {
-			p.buf.WriteString("#WV-AUDIO-FORMAT ")
-			p.buf.WriteString(strconv.FormatUint(uint64(p.W.AudioFormat), 10))
-			p.buf.WriteRune('\n')
+			buf.WriteString("#WV-AUDIO-FORMAT ")
+			buf.WriteString(strconv.FormatUint(uint64(p.W.AudioFormat), 10))
+			buf.WriteRune('\n')
 		}
 		if p.W.AudioProfileIDC != 0 {
-			p.buf.WriteString("#WV-AUDIO-PROFILE-IDC ")
-			p.buf.WriteString(strconv.FormatUint(uint64(p.W.AudioProfileIDC), 10))
-			p.buf.WriteRune('\n')
+			buf.WriteString("#WV-AUDIO-PROFILE-IDC ")
+			buf.WriteString(strconv.FormatUint(uint64(p.W.AudioProfileIDC), 10))
+			buf.WriteRune('\n')
 		}
 		if p.W.AudioSampleSize != 0 {
-			p.buf.WriteString("#WV-AUDIO-SAMPLE-SIZE ")
-			p.buf.WriteString(strconv.FormatUint(uint64(p.W.AudioSampleSize), 10))
-			p.buf.WriteRune('\n')
+			buf.WriteString("#WV-AUDIO-SAMPLE-SIZE ")
+			buf.WriteString(strconv.FormatUint(uint64(p.W.AudioSampleSize), 10))
+			buf.WriteRune('\n')
 		}
 		if p.W.AudioSamplingFrequency != 0 {
-			p.buf.WriteString("#WV-AUDIO-SAMPLING-FREQUENCY ")
-			p.buf.WriteString(strconv.FormatUint(uint64(p.W.AudioSamplingFrequency), 10))
-			p.buf.WriteRune('\n')
+			buf.WriteString("#WV-AUDIO-SAMPLING-FREQUENCY ")
+			buf.WriteString(strconv.FormatUint(uint64(p.W.AudioSamplingFrequency), 10))
+			buf.WriteRune('\n')
 		}
 		if p.W.CypherVersion != "" {
-			p.buf.WriteString("#WV-CYPHER-VERSION ")
-			p.buf.WriteString(p.W.CypherVersion)
-			p.buf.WriteRune('\n')
+			buf.WriteString("#WV-CYPHER-VERSION ")
+			buf.WriteString(p.W.CypherVersion)
+			buf.WriteRune('\n')
 		}
 		if p.W.ECM != "" {
-			p.buf.WriteString("#WV-ECM ")
-			p.buf.WriteString(p.W.ECM)
-			p.buf.WriteRune('\n')
+			buf.WriteString("#WV-ECM ")
+			buf.WriteString(p.W.ECM)
+			buf.WriteRune('\n')
 		}
 		if p.W.VideoFormat != 0 {
-			p.buf.WriteString("#WV-VIDEO-FORMAT ")
-			p.buf.WriteString(strconv.FormatUint(uint64(p.W.VideoFormat), 10))
-			p.buf.WriteRune('\n')
+			buf.WriteString("#WV-VIDEO-FORMAT ")
+			buf.WriteString(strconv.FormatUint(uint64(p.W.VideoFormat), 10))
+			buf.WriteRune('\n')
 		}
 		if p.W.VideoFrameRate != 0 {
-			p.buf.WriteString("#WV-VIDEO-FRAME-RATE ")
-			p.buf.WriteString(strconv.FormatUint(uint64(p.W.VideoFrameRate), 10))
-			p.buf.WriteRune('\n')
+			buf.WriteString("#WV-VIDEO-FRAME-RATE ")
+			buf.WriteString(strconv.FormatUint(uint64(p.W.VideoFrameRate), 10))
+			buf.WriteRune('\n')
 		}
 		if p.W.VideoLevelIDC != 0 {
-			p.buf.WriteString("#WV-VIDEO-LEVEL-IDC")
-			p.buf.WriteString(strconv.FormatUint(uint64(p.W.VideoLevelIDC), 10))
-			p.buf.WriteRune('\n')
+			buf.WriteString("#WV-VIDEO-LEVEL-IDC")
+			buf.WriteString(strconv.FormatUint(uint64(p.W.VideoLevelIDC), 10))
+			buf.WriteRune('\n')
 		}
 		if p.W.VideoProfileIDC != 0 {
-			p.buf.WriteString("#WV-VIDEO-PROFILE-IDC ")
-			p.buf.WriteString(strconv.FormatUint(uint64(p.W.VideoProfileIDC), 10))
-			p.buf.WriteRune('\n')
+			buf.WriteString("#WV-VIDEO-PROFILE-IDC ")
+			buf.WriteString(strconv.FormatUint(uint64(p.W.VideoProfileIDC), 10))
+			buf.WriteRune('\n')
 		}
 		if p.W.VideoResolution != "" {
-			p.buf.WriteString("#WV-VIDEO-RESOLUTION ")
-			p.buf.WriteString(p.W.VideoResolution)
-			p.buf.WriteRune('\n')
+			buf.WriteString("#WV-VIDEO-RESOLUTION ")
+			buf.WriteString(p.W.VideoResolution)
+			buf.WriteRune('\n')
 		}
 		if p.W.VideoSAR != "" {
-			p.buf.WriteString("#WV-VIDEO-SAR ")
-			p.buf.WriteString(p.W.VideoSAR)
-			p.buf.WriteRune('\n')
+			buf.WriteString("#WV-VIDEO-SAR ")
+			buf.WriteString(p.W.VideoSAR)
+			buf.WriteRune('\n')
 		}
 	}
 
@@ -471,8 +566,18 @@ func (p *MediaPlaylist) Encode() *bytes.Buffer {
 		durationCache = make(map[float64]string)
 	)
 
+	if skip > 0 {
+		buf.WriteString("#EXT-X-SKIP:SKIPPED-SEGMENTS=")
+		buf.WriteString(strconv.Itoa(skip))
+		if skipDateRanges {
+			// removed date ranges are not tracked
+			buf.WriteString(",RECENTLY-REMOVED-DATERANGES=\"\"")
+		}
+		buf.WriteRune('\n')
+	}
 	head := p.head
 	count := p.count
+	keys := defaultKeys // keys in effect
 	for i := 0; (i < p.winsize || p.winsize == 0) && count > 0; count-- {
 		seg = p.Segments[head]
 		head = (head + 1) % p.capacity
@@ -482,105 +587,177 @@ func (p *MediaPlaylist) Encode() *bytes.Buffer {
 		if p.winsize > 0 { // skip for VOD playlists, where winsize = 0
 			i++
 		}
+		if skip > 0 {
+			if segKeys := keySet(seg.Key, seg.Keys); len(segKeys) > 0 {
+				keys = segKeys
+			}
+			for _, dr := range seg.DateRanges {
+				if !skipDateRanges {
+					writeDateRange(buf, dr)
+				}
+			}
+			skip--
+			continue
+		}
 		if seg.SCTE != nil {
 			switch seg.SCTE.Syntax {
 			case Syntax672014:
-				p.buf.WriteString("#EXT-SCTE35:")
-				p.buf.WriteString("CUE=\"")
-				p.buf.WriteString(seg.SCTE.Cue)
-				p.buf.WriteRune('"')
+				buf.WriteString("#EXT-SCTE35:")
+				buf.WriteString("CUE=\"")
+				buf.WriteString(seg.SCTE.Cue)
+				buf.WriteRune('"')
 				if seg.SCTE.ID != "" {
-					p.buf.WriteString(",ID=\"")
-					p.buf.WriteString(seg.SCTE.ID)
-					p.buf.WriteRune('"')
+					buf.WriteString(",ID=\"")
+					buf.WriteString(seg.SCTE.ID)
+					buf.WriteRune('"')
 				}
 				if seg.SCTE.Time != 0 {
-					p.buf.WriteString(",TIME=")
-					p.buf.WriteString(strconv.FormatFloat(seg.SCTE.Time, 'f', -1, 64))
+					buf.WriteString(",TIME=")
+					buf.WriteString(strconv.FormatFloat(seg.SCTE.Time, 'f', -1, 64))
 				}
-				p.buf.WriteRune('\n')
+				buf.WriteRune('\n')
 			case SyntaxOATCLS:
 				switch seg.SCTE.CueType {
 				case SCTE35CueStart:
-					p.buf.WriteString("#EXT-OATCLS-SCTE35:")
-					p.buf.WriteString(seg.SCTE.Cue)
-					p.buf.WriteRune('\n')
-					p.buf.WriteString("#EXT-X-CUE-OUT:")
-					p.buf.WriteString(strconv.FormatFloat(seg.SCTE.Time, 'f', -1, 64))
-					p.buf.WriteRune('\n')
+					buf.WriteString("#EXT-OATCLS-SCTE35:")
+					buf.WriteString(seg.SCTE.Cue)
+					buf.WriteRune('\n')
+					buf.WriteString("#EXT-X-CUE-OUT:")
+					buf.WriteString(strconv.FormatFloat(seg.SCTE.Time, 'f', -1, 64))
+					buf.WriteRune('\n')
 				case SCTE35CueMid:
-					p.buf.WriteString("#EXT-X-CUE-OUT-CONT:")
-					p.buf.WriteString("ElapsedTime=")
-					p.buf.WriteString(strconv.FormatFloat(seg.SCTE.Elapsed, 'f', -1, 64))
-					p.buf.WriteString(",Duration=")
-					p.buf.WriteString(strconv.FormatFloat(seg.SCTE.Time, 'f', -1, 64))
-					p.buf.WriteString(",SCTE35=")
-					p.buf.WriteString(seg.SCTE.Cue)
-					p.buf.WriteRune('\n')
+					buf.WriteString("#EXT-X-CUE-OUT-CONT:")
+					buf.WriteString("ElapsedTime=")
+					buf.WriteString(strconv.FormatFloat(seg.SCTE.Elapsed, 'f', -1, 64))
+					buf.WriteString(",Duration=")
+					buf.WriteString(strconv.FormatFloat(seg.SCTE.Time, 'f', -1, 64))
+					buf.WriteString(",SCTE35=")
+					buf.WriteString(seg.SCTE.Cue)
+					buf.WriteRune('\n')
 				case SCTE35CueEnd:
-					p.buf.WriteString("#EXT-X-CUE-IN")
-					p.buf.WriteRune('\n')
+					buf.WriteString("#EXT-X-CUE-IN")
+					buf.WriteRune('\n')
 				}
-			}
-		}
-		// check for key change
-		if seg.Key != nil && p.Key != seg.Key {
-			p.buf.WriteString("#EXT-X-KEY:")
-			p.buf.WriteString("METHOD=")
-			p.buf.WriteString(seg.Key.Method)
-			if seg.Key.Method != "NONE" {
-				p.buf.WriteString(",URI=\"")
-				p.buf.WriteString(seg.Key.URI)
-				p.buf.WriteRune('"')
-				if seg.Key.IV != "" {
-					p.buf.WriteString(",IV=")
-					p.buf.WriteString(seg.Key.IV)
+			case SyntaxElemental:
+				switch seg.SCTE.CueType {
+				case SCTE35CueStart:
+					buf.WriteString("#EXT-X-CUE-OUT:DURATION=")
+					buf.WriteString(strconv.FormatFloat(seg.SCTE.Time, 'f', -1, 64))
+					buf.WriteRune('\n')
+				case SCTE35CueMid:
+					buf.WriteString("#EXT-X-CUE-OUT-CONT:")
+					buf.WriteString(strconv.FormatFloat(seg.SCTE.Elapsed, 'f', -1, 64))
+					buf.WriteRune('/')
+					buf.WriteString(strconv.FormatFloat(seg.SCTE.Time, 'f', -1, 64))
+					buf.WriteRune('\n')
+				case SCTE35CueEnd:
+					buf.WriteString("#EXT-X-CUE-IN")
+					buf.WriteRune('\n')
 				}
-				if seg.Key.Keyformat != "" {
-					p.buf.WriteString(",KEYFORMAT=\"")
-					p.buf.WriteString(seg.Key.Keyformat)
-					p.buf.WriteRune('"')
+			case SyntaxAdobe:
+				// Adobe has no tag for segments in the middle of a break
+				if seg.SCTE.CueType != SCTE35CueMid {
+					buf.WriteString("#EXT-X-CUE:TYPE=")
+					if seg.SCTE.CueType == SCTE35CueStart {
+						buf.WriteString("\"SpliceOut\"")
+					} else {
+						buf.WriteString("\"SpliceIn\"")
+					}
+					if seg.SCTE.ID != "" {
+						buf.WriteString(",ID=\"")
+						buf.WriteString(seg.SCTE.ID)
+						buf.WriteRune('"')
+					}
+					if seg.SCTE.Time != 0 {
+						buf.WriteString(",DURATION=")
+						buf.WriteString(strconv.FormatFloat(seg.SCTE.Time, 'f', -1, 64))
+					}
+					if seg.SCTE.Position != 0 {
+						buf.WriteString(",TIME=")
+						buf.WriteString(strconv.FormatFloat(seg.SCTE.Position, 'f', -1, 64))
+					}
+					if seg.SCTE.Cue != "" {
+						buf.WriteString(",CUE=\"")
+						buf.WriteString(seg.SCTE.Cue)
+						buf.WriteRune('"')
+					}
+					buf.WriteRune('\n')
 				}
-				if seg.Key.Keyformatversions != "" {
-					p.buf.WriteString(",KEYFORMATVERSIONS=\"")
-					p.buf.WriteString(seg.Key.Keyformatversions)
-					p.buf.WriteRune('"')
+			case SyntaxAdobeSplicePoint:
+				buf.WriteString("#EXT-X-SPLICEPOINT-SCTE35:")
+				buf.WriteString(seg.SCTE.Cue)
+				buf.WriteRune('\n')
+			case SyntaxElementalSCTE35:
+				buf.WriteString("#EXT-X-SCTE35:")
+				buf.WriteString("CUE=\"")
+				buf.WriteString(seg.SCTE.Cue)
+				buf.WriteRune('"')
+				if seg.SCTE.ID != "" {
+					buf.WriteString(",ID=\"")
+					buf.WriteString(seg.SCTE.ID)
+					buf.WriteRune('"')
 				}
+				switch seg.SCTE.CueType {
+				case SCTE35CueStart:
+					buf.WriteString(",CUE-OUT=YES")
+				case SCTE35CueMid:
+					buf.WriteString(",CUE-OUT=CONT")
+				case SCTE35CueEnd:
+					buf.WriteString(",CUE-IN=YES")
+				}
+				if seg.SCTE.Time != 0 {
+					buf.WriteString(",DURATION=")
+					buf.WriteString(strconv.FormatFloat(seg.SCTE.Time, 'f', -1, 64))
+				}
+				if seg.SCTE.Elapsed != 0 {
+					buf.WriteString(",ELAPSED=")
+					buf.WriteString(strconv.FormatFloat(seg.SCTE.Elapsed, 'f', -1, 64))
+				}
+				buf.WriteRune('\n')
 			}
-			p.buf.WriteRune('\n')
+		}
+		for _, dr := range seg.DateRanges {
+			writeDateRange(buf, dr)
+		}
+		// check for key change
+		if segKeys := keySet(seg.Key, seg.Keys); len(segKeys) > 0 && !sameKeys(segKeys, keys) {
+			for _, key := range segKeys {
+				writeKey(buf, key, rw)
+			}
+			keys = segKeys
 		}
 		if seg.Discontinuity {
-			p.buf.WriteString("#EXT-X-DISCONTINUITY\n")
+			buf.WriteString("#EXT-X-DISCONTINUITY\n")
 		}
 		// ignore segment Map if default playlist Map is present
 		if p.Map == nil && seg.Map != nil {
-			p.buf.WriteString("#EXT-X-MAP:")
-			p.buf.WriteString("URI=\"")
-			p.buf.WriteString(seg.Map.URI)
-			p.buf.WriteRune('"')
+			buf.WriteString("#EXT-X-MAP:")
+			buf.WriteString("URI=\"")
+			buf.WriteString(rewriteURI(rw, URIMap, seg.Map.URI))
+			buf.WriteRune('"')
 			if seg.Map.Limit > 0 {
-				p.buf.WriteString(",BYTERANGE=")
-				p.buf.WriteString(strconv.Itoa(seg.Map.Limit))
-				p.buf.WriteRune('@')
-				p.buf.WriteString(strconv.Itoa(seg.Map.Offset))
+				buf.WriteString(",BYTERANGE=")
+				buf.WriteString(strconv.Itoa(seg.Map.Limit))
+				buf.WriteRune('@')
+				buf.WriteString(strconv.Itoa(seg.Map.Offset))
 			}
-			p.buf.WriteRune('\n')
+			buf.WriteRune('\n')
 		}
 		if !seg.ProgramDateTime.IsZero() {
-			p.buf.WriteString("#EXT-X-PROGRAM-DATE-TIME:")
-			p.buf.WriteString(seg.ProgramDateTime.Format(DateTime))
-			p.buf.WriteRune('\n')
+			buf.WriteString("#EXT-X-PROGRAM-DATE-TIME:")
+			buf.WriteString(seg.ProgramDateTime.Format(DateTime))
+			buf.WriteRune('\n')
 		}
 		if seg.Limit > 0 {
-			p.buf.WriteString("#EXT-X-BYTERANGE:")
-			p.buf.WriteString(strconv.Itoa(seg.Limit))
-			p.buf.WriteRune('@')
-			p.buf.WriteString(strconv.Itoa(seg.Offset))
-			p.buf.WriteRune('\n')
+			buf.WriteString("#EXT-X-BYTERANGE:")
+			buf.WriteString(strconv.Itoa(seg.Limit))
+			buf.WriteRune('@')
+			buf.WriteString(strconv.Itoa(seg.Offset))
+			buf.WriteRune('\n')
 		}
-		p.buf.WriteString("#EXTINF:")
+		buf.WriteString("#EXTINF:")
 		if str, ok := durationCache[seg.Duration]; ok {
-			p.buf.WriteString(str)
+			buf.WriteString(str)
 		} else {
 			if p.durationAsInt {
 				// Old Android players has problems with non integer Duration.
@@ -589,22 +766,21 @@ func (p *MediaPlaylist) Encode() *bytes.Buffer {
 				// Wowza Mediaserver and some others prefer floats.
 				durationCache[seg.Duration] = strconv.FormatFloat(seg.Duration, 'f', 3, 32)
 			}
-			p.buf.WriteString(durationCache[seg.Duration])
+			buf.WriteString(durationCache[seg.Duration])
 		}
-		p.buf.WriteRune(',')
-		p.buf.WriteString(seg.Title)
-		p.buf.WriteRune('\n')
-		p.buf.WriteString(seg.URI)
+		buf.WriteRune(',')
+		buf.WriteString(seg.Title)
+		buf.WriteRune('\n')
+		uri := seg.URI
 		if p.Args != "" {
-			p.buf.WriteRune('?')
-			p.buf.WriteString(p.Args)
+			uri = AppendQuery(uri, p.Args)
 		}
-		p.buf.WriteRune('\n')
+		buf.WriteString(rewriteURI(rw, URISegment, uri))
+		buf.WriteRune('\n')
 	}
 	if p.Closed {
-		p.buf.WriteString("#EXT-X-ENDLIST\n")
+		buf.WriteString("#EXT-X-ENDLIST\n")
 	}
-	return &p.buf
 }
 
 // String returns the encoded buffer in string format,
@@ -633,6 +809,7 @@ func (p *MediaPlaylist) Close() {
 		p.buf.WriteString("#EXT-X-ENDLIST\n")
 	}
 	p.Closed = true
+	p.publish(EventClose, nil, 0)
 }
 
 // SetDefaultKey sets the encryption key appeared once in header of the playlist
@@ -640,14 +817,26 @@ func (p *MediaPlaylist) Close() {
 // The tag set applies for the whole list.
 // It is useful when keys are not changed during playback.
 func (p *MediaPlaylist) SetDefaultKey(method, uri, iv, keyformat, keyformatversions string) error {
+	return p.SetDefaultKeys(&Key{method, uri, iv, keyformat, keyformatversions})
+}
+
+// SetDefaultKeys sets the set of encryption keys appeared once in header of
+// the playlist, one EXT-X-KEY per key format (e.g. for FairPlay, Widevine and
+// PlayReady). MediaPlaylist.Key is set to the first of them.
+func (p *MediaPlaylist) SetDefaultKeys(keys ...*Key) error {
+	if err := validateKeys(keys); err != nil {
+		return err
+	}
 	// A Media Playlist MUST indicate a EXT-X-VERSION of 5 or higher if it
 	// contains:
 	//   - The KEYFORMAT and KEYFORMATVERSIONS attributes of the EXT-X-KEY tag.
-	if keyformat != "" || keyformatversions != "" {
-		checkVersion(&p.ver, 5)
+	//   - The SAMPLE-AES METHOD of the EXT-X-KEY tag.
+	checkKeysVersion(&p.ver, keys)
+	p.Key = keys[0]
+	p.Keys = nil
+	if len(keys) > 1 {
+		p.Keys = keys
 	}
-	p.Key = &Key{method, uri, iv, keyformat, keyformatversions}
-
 	return nil
 }
 
@@ -669,18 +858,31 @@ func (p *MediaPlaylist) SetIframeOnly() {
 // SetKey sets a encryption key for the current segment of media playlist
 // (pointer to Segment.Key).
 func (p *MediaPlaylist) SetKey(method, uri, iv, keyformat, keyformatversions string) error {
+	return p.SetKeys(&Key{method, uri, iv, keyformat, keyformatversions})
+}
+
+// SetKeys sets the set of encryption keys for the current segment of media
+// playlist, one EXT-X-KEY per key format. Segment.Key is set to the first of them.
+func (p *MediaPlaylist) SetKeys(keys ...*Key) error {
 	if p.count == 0 {
 		return errors.New("playlist is empty")
 	}
+	if err := validateKeys(keys); err != nil {
+		return err
+	}
 
 	// A Media Playlist MUST indicate a EXT-X-VERSION of 5 or higher if it
 	// contains:
 	//   - The KEYFORMAT and KEYFORMATVERSIONS attributes of the EXT-X-KEY tag.
-	if keyformat != "" || keyformatversions != "" {
-		checkVersion(&p.ver, 5)
-	}
+	//   - The SAMPLE-AES METHOD of the EXT-X-KEY tag.
+	checkKeysVersion(&p.ver, keys)
 
-	p.Segments[p.last()].Key = &Key{method, uri, iv, keyformat, keyformatversions}
+	seg := p.Segments[p.last()]
+	seg.Key = keys[0]
+	seg.Keys = nil
+	if len(keys) > 1 {
+		seg.Keys = keys
+	}
 	return nil
 }
 
@@ -716,6 +918,16 @@ func (p *MediaPlaylist) SetSCTE35(scte35 *SCTE) error {
 	return nil
 }
 
+// SetDateRange adds an EXT-X-DATERANGE tag to the current media segment.
+func (p *MediaPlaylist) SetDateRange(dr *DateRange) error {
+	if p.count == 0 {
+		return errors.New("playlist is empty")
+	}
+	seg := p.Segments[p.last()]
+	seg.DateRanges = append(seg.DateRanges, dr)
+	return nil
+}
+
 // SetDiscontinuity sets the discontinuity-flag for the current media segment.
 // EXT-X-DISCONTINUITY indicates an encoding discontinuity between the media segment
 // that follows it and the one that preceded it (i.e. file format, number and type of tracks,
@@ -725,6 +937,7 @@ func (p *MediaPlaylist) SetDiscontinuity() error {
 		return errors.New("playlist is empty")
 	}
 	p.Segments[p.last()].Discontinuity = true
+	p.index = nil
 	return nil
 }
 
@@ -738,6 +951,7 @@ func (p *MediaPlaylist) SetProgramDateTime(value time.Time) error {
 		return errors.New("playlist is empty")
 	}
 	p.Segments[p.last()].ProgramDateTime = value
+	p.index = nil // the times of the segments changed
 	return nil
 }
 
@@ -777,3 +991,160 @@ func (p *MediaPlaylist) ExtendCapacity() (err error) {
 	p.tail = p.count
 	return
 }
+
+// writeDateRange writes the EXT-X-DATERANGE tag, see section 4.3.2.7.
+func writeDateRange(buf *bytes.Buffer, dr *DateRange) {
+	buf.WriteString("#EXT-X-DATERANGE:ID=\"")
+	buf.WriteString(dr.ID)
+	buf.WriteRune('"')
+	if dr.Class != "" {
+		buf.WriteString(",CLASS=\"")
+		buf.WriteString(dr.Class)
+		buf.WriteRune('"')
+	}
+	buf.WriteString(",START-DATE=\"")
+	buf.WriteString(dr.StartDate.Format(DateTime))
+	buf.WriteRune('"')
+	if !dr.EndDate.IsZero() {
+		buf.WriteString(",END-DATE=\"")
+		buf.WriteString(dr.EndDate.Format(DateTime))
+		buf.WriteRune('"')
+	}
+	if dr.Duration != 0 {
+		buf.WriteString(",DURATION=")
+		buf.WriteString(strconv.FormatFloat(dr.Duration, 'f', -1, 64))
+	}
+	if dr.PlannedDuration != 0 {
+		buf.WriteString(",PLANNED-DURATION=")
+		buf.WriteString(strconv.FormatFloat(dr.PlannedDuration, 'f', -1, 64))
+	}
+	if len(dr.X) > 0 {
+		keys := make([]string, 0, len(dr.X))
+		for k := range dr.X {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			v := dr.X[k]
+			buf.WriteRune(',')
+			buf.WriteString(k)
+			buf.WriteRune('=')
+			quoted, ok := dr.XQuoted[k]
+			if !ok {
+				// hexadecimal and decimal values are not quoted
+				_, err := strconv.ParseFloat(v, 64)
+				quoted = err != nil && !strings.HasPrefix(v, "0x") && !strings.HasPrefix(v, "0X")
+			}
+			if !quoted {
+				buf.WriteString(v)
+			} else {
+				buf.WriteRune('"')
+				buf.WriteString(v)
+				buf.WriteRune('"')
+			}
+		}
+	}
+	if dr.SCTE35Cmd != "" {
+		buf.WriteString(",SCTE35-CMD=")
+		buf.WriteString(dr.SCTE35Cmd)
+	}
+	if dr.SCTE35Out != "" {
+		buf.WriteString(",SCTE35-OUT=")
+		buf.WriteString(dr.SCTE35Out)
+	}
+	if dr.SCTE35In != "" {
+		buf.WriteString(",SCTE35-IN=")
+		buf.WriteString(dr.SCTE35In)
+	}
+	if dr.EndOnNext {
+		buf.WriteString(",END-ON-NEXT=YES")
+	}
+	buf.WriteRune('\n')
+}
+
+// keySet returns the EXT-X-KEY tags of a segment or playlist: keys if it is
+// consistent with key (its first element), otherwise the single key.
+// Setting only the Key field therefore replaces the whole set.
+func keySet(key *Key, keys []*Key) []*Key {
+	if key == nil {
+		return nil
+	}
+	if len(keys) > 0 && keys[0] == key {
+		return keys
+	}
+	return []*Key{key}
+}
+
+// sameKeys reports whether both sets hold keys with the same attributes.
+func sameKeys(a, b []*Key) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if *a[i] != *b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// checkKeysVersion sets version 5 if any key uses KEYFORMAT,
+// KEYFORMATVERSIONS or a SAMPLE-AES method.
+func checkKeysVersion(ver *int, keys []*Key) {
+	for _, k := range keys {
+		if k.Keyformat != "" || k.Keyformatversions != "" ||
+			k.Method == KeyMethodSampleAES || k.Method == KeyMethodSampleAESCTR {
+			checkVersion(ver, 5)
+		}
+	}
+}
+
+// writeKey writes the EXT-X-KEY tag, rewriting its URI with rw if not nil.
+func writeKey(buf *bytes.Buffer, key *Key, rw URIRewriter) {
+	buf.WriteString("#EXT-X-KEY:")
+	buf.WriteString("METHOD=")
+	buf.WriteString(key.Method)
+	if key.Method != KeyMethodNone {
+		buf.WriteString(",URI=\"")
+		buf.WriteString(rewriteURI(rw, URIKey, key.URI))
+		buf.WriteRune('"')
+		if key.IV != "" {
+			buf.WriteString(",IV=")
+			buf.WriteString(key.IV)
+		}
+		if key.Keyformat != "" {
+			buf.WriteString(",KEYFORMAT=\"")
+			buf.WriteString(key.Keyformat)
+			buf.WriteRune('"')
+		}
+		if key.Keyformatversions != "" {
+			buf.WriteString(",KEYFORMATVERSIONS=\"")
+			buf.WriteString(key.Keyformatversions)
+			buf.WriteRune('"')
+		}
+	}
+	buf.WriteRune('\n')
+}
+
+// writeServerControl writes the EXT-X-SERVER-CONTROL tag.
+func writeServerControl(buf *bytes.Buffer, sc *ServerControl) {
+	buf.WriteString("#EXT-X-SERVER-CONTROL:")
+	var attrs []string
+	if sc.CanSkipUntil > 0 {
+		attrs = append(attrs, "CAN-SKIP-UNTIL="+strconv.FormatFloat(sc.CanSkipUntil, 'f', -1, 64))
+	}
+	if sc.CanSkipDateRanges {
+		attrs = append(attrs, "CAN-SKIP-DATERANGES=YES")
+	}
+	if sc.HoldBack > 0 {
+		attrs = append(attrs, "HOLD-BACK="+strconv.FormatFloat(sc.HoldBack, 'f', -1, 64))
+	}
+	if sc.PartHoldBack > 0 {
+		attrs = append(attrs, "PART-HOLD-BACK="+strconv.FormatFloat(sc.PartHoldBack, 'f', -1, 64))
+	}
+	if sc.CanBlockReload {
+		attrs = append(attrs, "CAN-BLOCK-RELOAD=YES")
+	}
+	buf.WriteString(strings.Join(attrs, ","))
+	buf.WriteRune('\n')
+}
@@ -207,6 +207,124 @@ func TestSetSCTEForMediaPlaylist(t *testing.T) {
 	}
 }
 
+// Create new media playlist
+// Add segment to media playlist
+// Set SCTE in the other syntaxes
+func TestSetSCTESyntaxesForMediaPlaylist(t *testing.T) {
+	tests := []struct {
+		SCTE     *hls.SCTE
+		Expected string
+	}{
+		{&hls.SCTE{Syntax: hls.SyntaxElemental, CueType: hls.SCTE35CueStart, Time: 30}, "#EXT-X-CUE-OUT:DURATION=30\n"},
+		{&hls.SCTE{Syntax: hls.SyntaxElemental, CueType: hls.SCTE35CueMid, Time: 30, Elapsed: 10.5}, "#EXT-X-CUE-OUT-CONT:10.5/30\n"},
+		{&hls.SCTE{Syntax: hls.SyntaxElemental, CueType: hls.SCTE35CueEnd}, "#EXT-X-CUE-IN\n"},
+		{&hls.SCTE{Syntax: hls.SyntaxAdobe, CueType: hls.SCTE35CueStart, ID: "1", Time: 30, Position: 10, Cue: "CueData"}, `#EXT-X-CUE:TYPE="SpliceOut",ID="1",DURATION=30,TIME=10,CUE="CueData"` + "\n"},
+		{&hls.SCTE{Syntax: hls.SyntaxAdobe, CueType: hls.SCTE35CueEnd, ID: "1"}, `#EXT-X-CUE:TYPE="SpliceIn",ID="1"` + "\n"},
+		{&hls.SCTE{Syntax: hls.SyntaxAdobeSplicePoint, Cue: "CueData"}, "#EXT-X-SPLICEPOINT-SCTE35:CueData\n"},
+		{&hls.SCTE{Syntax: hls.SyntaxElementalSCTE35, CueType: hls.SCTE35CueStart, Cue: "CueData", Time: 30}, `#EXT-X-SCTE35:CUE="CueData",CUE-OUT=YES,DURATION=30` + "\n"},
+		{&hls.SCTE{Syntax: hls.SyntaxElementalSCTE35, CueType: hls.SCTE35CueMid, Cue: "CueData", Time: 30, Elapsed: 10}, `#EXT-X-SCTE35:CUE="CueData",CUE-OUT=CONT,DURATION=30,ELAPSED=10` + "\n"},
+		{&hls.SCTE{Syntax: hls.SyntaxElementalSCTE35, CueType: hls.SCTE35CueEnd, Cue: "CueData"}, `#EXT-X-SCTE35:CUE="CueData",CUE-IN=YES` + "\n"},
+	}
+
+	for _, test := range tests {
+		p, e := hls.NewMediaPlaylist(1, 1)
+		if e != nil {
+			t.Fatalf("Create media playlist failed: %s", e)
+		}
+		if e = p.Append(hls.QuickSegment("test01.ts", "title", 5.0)); e != nil {
+			t.Errorf("Add 1st segment to a media playlist failed: %s", e)
+		}
+		if e := p.SetSCTE35(test.SCTE); e != nil {
+			t.Errorf("SetSCTE to a media playlist failed: %s", e)
+		}
+		if !strings.Contains(p.String(), test.Expected) {
+			t.Errorf("Test %+v did not contain: %q, playlist: %v", test.SCTE, test.Expected, p.String())
+		}
+	}
+}
+
+func TestSetDateRangeForMediaPlaylist(t *testing.T) {
+	p, _ := hls.NewMediaPlaylist(1, 1)
+	dr := &hls.DateRange{
+		ID:              "splice-1",
+		Class:           "ad",
+		StartDate:       time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		PlannedDuration: 15,
+		SCTE35Out:       "0xFC30",
+		X:               map[string]string{"X-AD-ID": "abc", "X-COUNT": "2"},
+	}
+	if err := p.SetDateRange(dr); err == nil {
+		t.Error("SetDateRange expected empty playlist error")
+	}
+	p.Append(hls.QuickSegment("test01.ts", "title", 5.0))
+	if err := p.SetDateRange(dr); err != nil {
+		t.Fatal(err)
+	}
+	expected := `#EXT-X-DATERANGE:ID="splice-1",CLASS="ad",START-DATE="2020-01-01T00:00:00Z",PLANNED-DURATION=15,X-AD-ID="abc",X-COUNT=2,SCTE35-OUT=0xFC30` + "\n"
+	if !strings.Contains(p.String(), expected) {
+		t.Errorf("Expected %q in:\n%s", expected, p)
+	}
+}
+
+// Create new media playlist
+// Set default keys for FairPlay and Widevine
+// Rotate both keys on the 3rd segment
+func TestSetKeysForMediaPlaylist(t *testing.T) {
+	p, _ := hls.NewMediaPlaylist(3, 3)
+	if err := p.SetKeys(&hls.Key{Method: "SAMPLE-AES", URI: "skd://key1"}); err == nil {
+		t.Error("SetKeys expected empty playlist error")
+	}
+	if err := p.SetDefaultKeys(
+		&hls.Key{Method: "SAMPLE-AES", URI: "skd://key1", Keyformat: "com.apple.streamingkeydelivery", Keyformatversions: "1"},
+		&hls.Key{Method: "SAMPLE-AES", URI: "data:text/plain;base64,AAAA", Keyformat: "urn:uuid:edef8ba9-79d6-4ace-a3c8-27dcd51d21ed", Keyformatversions: "1"},
+	); err != nil {
+		t.Fatal(err)
+	}
+	if p.Version() < 5 {
+		t.Errorf("Expected version 5 for KEYFORMAT, got %d", p.Version())
+	}
+	for i := 0; i < 3; i++ {
+		p.Append(hls.QuickSegment(fmt.Sprintf("test%d.ts", i), "", 10))
+	}
+	if err := p.SetKeys(
+		&hls.Key{Method: "SAMPLE-AES", URI: "skd://key2", Keyformat: "com.apple.streamingkeydelivery", Keyformatversions: "1"},
+		&hls.Key{Method: "SAMPLE-AES", URI: "data:text/plain;base64,BBBB", Keyformat: "urn:uuid:edef8ba9-79d6-4ace-a3c8-27dcd51d21ed", Keyformatversions: "1"},
+	); err != nil {
+		t.Fatal(err)
+	}
+	expected := `#EXT-X-KEY:METHOD=SAMPLE-AES,URI="skd://key2",KEYFORMAT="com.apple.streamingkeydelivery",KEYFORMATVERSIONS="1"
+#EXT-X-KEY:METHOD=SAMPLE-AES,URI="data:text/plain;base64,BBBB",KEYFORMAT="urn:uuid:edef8ba9-79d6-4ace-a3c8-27dcd51d21ed",KEYFORMATVERSIONS="1"
+#EXTINF:10.000,
+test2.ts
+`
+	out := p.String()
+	if !strings.HasSuffix(out, expected) {
+		t.Errorf("Expected suffix:\n%s\ngot:\n%s", expected, out)
+	}
+	if n := strings.Count(out, "#EXT-X-KEY:"); n != 4 {
+		t.Errorf("Expected 4 EXT-X-KEY tags, got %d", n)
+	}
+}
+
+func TestEncodeKeysRotatedBack(t *testing.T) {
+	p, _ := hls.NewMediaPlaylist(0, 3)
+	p.SetDefaultKey("AES-128", "key1", "", "", "")
+	for i := 0; i < 3; i++ {
+		p.Append(hls.QuickSegment(fmt.Sprintf("test%d.ts", i), "", 10))
+		switch i {
+		case 1:
+			p.SetKey("AES-128", "key2", "", "", "")
+		case 2: // an equal key back, not the default one itself
+			p.SetKey("AES-128", "key1", "", "", "")
+		}
+	}
+	out := p.String()
+	expected := "test0.ts\n#EXT-X-KEY:METHOD=AES-128,URI=\"key2\"\n#EXTINF:10.000,\ntest1.ts\n#EXT-X-KEY:METHOD=AES-128,URI=\"key1\"\n#EXTINF:10.000,\ntest2.ts\n"
+	if !strings.Contains(out, expected) || strings.Count(out, "#EXT-X-KEY:") != 3 {
+		t.Errorf("Expected %q in:\n%s", expected, out)
+	}
+}
+
 // Create new media playlist
 // Add segment to media playlist
 // Set encryption key
@@ -230,7 +348,7 @@ func TestSetKeyForMediaPlaylist(t *testing.T) {
 		if e = p.Append(hls.QuickSegment("test01.ts", "title", 5.0)); e != nil {
 			t.Errorf("Add 1st segment to a media playlist failed: %s", e)
 		}
-		if e := p.SetKey("AES-128", "https://example.com", "iv", test.KeyFormat, test.KeyFormatVersions); e != nil {
+		if e := p.SetKey("AES-128", "https://example.com", "0x00000000000000000000000000000001", test.KeyFormat, test.KeyFormatVersions); e != nil {
 			t.Errorf("Set key to a media playlist failed: %s", e)
 		}
 		if p.Version() != test.ExpectVersion {
@@ -259,7 +377,7 @@ func TestSetDefaultKeyForMediaPlaylist(t *testing.T) {
 		if e != nil {
 			t.Fatalf("Create media playlist failed: %s", e)
 		}
-		if e := p.SetDefaultKey("AES-128", "https://example.com", "iv", test.KeyFormat, test.KeyFormatVersions); e != nil {
+		if e := p.SetDefaultKey("AES-128", "https://example.com", "0x00000000000000000000000000000001", test.KeyFormat, test.KeyFormatVersions); e != nil {
 			t.Errorf("Set key to a media playlist failed: %s", e)
 		}
 		if p.Version() != test.ExpectVersion {
@@ -385,7 +503,7 @@ func TestEncryptionKeysInMediaPlaylist(t *testing.T) {
 		expected := &hls.Key{
 			Method:            "AES-128",
 			URI:               uri,
-			IV:                fmt.Sprintf("%d", i),
+			IV:                fmt.Sprintf("0x%032X", i),
 			Keyformat:         "identity",
 			Keyformatversions: "1",
 		}
@@ -407,7 +525,7 @@ func TestEncryptionKeyMethodNoneInMediaPlaylist(t *testing.T) {
 		t.Fatalf("Create media playlist failed: %s", e)
 	}
 	p.Append(hls.QuickSegment("segment-1.ts", "", 4))
-	p.SetKey("AES-128", "key-uri", "iv", "identity", "1")
+	p.SetKey("AES-128", "key-uri", "0x00000000000000000000000000000001", "identity", "1")
 	p.Append(hls.QuickSegment("segment-2.ts", "", 4))
 	p.SetKey("NONE", "", "", "", "")
 	expected := `#EXT-X-KEY:METHOD=NONE
@@ -760,3 +878,15 @@ func TestMasterSetVersion(t *testing.T) {
 		t.Errorf("Expected version: %v, got: %v", 5, m.Version())
 	}
 }
+
+func TestDateRangeClientAttributesRoundTrip(t *testing.T) {
+	const tag = `#EXT-X-DATERANGE:ID="ad",START-DATE="2020-01-01T00:00:00Z",X-COUNT=2,X-ID="123",X-NAME="abc"`
+	playlist := "#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-TARGETDURATION:10\n" + tag + "\n#EXTINF:10.000,\nmedia0.ts\n"
+	p, _, err := hls.DecodeFrom(strings.NewReader(playlist), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out := p.String(); !strings.Contains(out, tag+"\n") {
+		t.Errorf("Expected %q in:\n%s", tag, out)
+	}
+}